@@ -0,0 +1 @@
+DROP TABLE config_revisions;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE configs DROP COLUMN version;
\ No newline at end of file
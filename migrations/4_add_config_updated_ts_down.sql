@@ -0,0 +1 @@
+ALTER TABLE configs DROP COLUMN updated_ts;
\ No newline at end of file
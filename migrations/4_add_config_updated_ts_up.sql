@@ -0,0 +1 @@
+ALTER TABLE configs ADD COLUMN updated_ts BIGINT NOT NULL DEFAULT 0;
\ No newline at end of file
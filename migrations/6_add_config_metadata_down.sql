@@ -0,0 +1,2 @@
+ALTER TABLE configs DROP COLUMN metadata;
+ALTER TABLE config_revisions DROP COLUMN metadata;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE configs ADD COLUMN metadata JSON NOT NULL DEFAULT '{}';
+ALTER TABLE config_revisions ADD COLUMN metadata JSON NULL;
\ No newline at end of file
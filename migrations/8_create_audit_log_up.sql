@@ -0,0 +1,13 @@
+CREATE TABLE audit_log (
+	id BIGSERIAL NOT NULL,
+	created_ts BIGINT NOT NULL,
+	actor TEXT NOT NULL,
+	remote_addr TEXT NOT NULL,
+	hostname TEXT NOT NULL,
+	action TEXT NOT NULL,
+	diff JSON NOT NULL,
+
+  CONSTRAINT audit_log_id_unique UNIQUE (id)
+);
+CREATE INDEX audit_log_hostname ON audit_log (hostname);
+CREATE INDEX audit_log_created_ts ON audit_log (created_ts);
\ No newline at end of file
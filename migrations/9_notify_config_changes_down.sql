@@ -0,0 +1,3 @@
+DROP TRIGGER configs_notify_change ON configs;
+DROP FUNCTION notify_config_change();
+DROP TABLE cache_bus_channels;
\ No newline at end of file
@@ -0,0 +1,19 @@
+CREATE TABLE cache_bus_channels (
+	channel TEXT NOT NULL,
+
+  CONSTRAINT cache_bus_channels_channel_unique UNIQUE (channel)
+);
+CREATE FUNCTION notify_config_change() RETURNS TRIGGER AS $$
+DECLARE
+	changed TEXT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		changed := OLD.hostname;
+	ELSE
+		changed := NEW.hostname;
+	END IF;
+	PERFORM pg_notify(channel, json_build_object('origin', '', 'domain', changed)::TEXT) FROM cache_bus_channels;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER configs_notify_change AFTER INSERT OR UPDATE OR DELETE ON configs FOR EACH ROW EXECUTE PROCEDURE notify_config_change();
\ No newline at end of file
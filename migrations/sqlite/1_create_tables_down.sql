@@ -0,0 +1 @@
+DROP TABLE configs;
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE configs (
+	hostname TEXT NOT NULL,
+	config TEXT NOT NULL,
+
+  CONSTRAINT hostname_unique UNIQUE (hostname)
+);
\ No newline at end of file
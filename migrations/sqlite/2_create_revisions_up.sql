@@ -0,0 +1,10 @@
+CREATE TABLE config_revisions (
+	hostname TEXT NOT NULL,
+	revision INT NOT NULL,
+	created_ts BIGINT NOT NULL,
+	actor TEXT NOT NULL,
+	action TEXT NOT NULL,
+	config TEXT NULL,
+
+  CONSTRAINT hostname_revision_unique UNIQUE (hostname, revision)
+);
\ No newline at end of file
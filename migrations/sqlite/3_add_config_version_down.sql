@@ -0,0 +1,9 @@
+CREATE TABLE configs_old (
+	hostname TEXT NOT NULL,
+	config TEXT NOT NULL,
+
+  CONSTRAINT hostname_unique UNIQUE (hostname)
+);
+INSERT INTO configs_old (hostname, config) SELECT hostname, config FROM configs;
+DROP TABLE configs;
+ALTER TABLE configs_old RENAME TO configs;
\ No newline at end of file
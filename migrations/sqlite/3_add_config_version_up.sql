@@ -0,0 +1 @@
+ALTER TABLE configs ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE configs_old (
+	hostname TEXT NOT NULL,
+	config TEXT NOT NULL,
+	version BIGINT NOT NULL DEFAULT 1,
+
+  CONSTRAINT hostname_unique UNIQUE (hostname)
+);
+INSERT INTO configs_old (hostname, config, version) SELECT hostname, config, version FROM configs;
+DROP TABLE configs;
+ALTER TABLE configs_old RENAME TO configs;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE api_tokens (
+	id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	salt TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	domains TEXT NOT NULL,
+	permissions TEXT NOT NULL,
+	created_ts BIGINT NOT NULL,
+	expires_ts BIGINT NOT NULL,
+	revoked BOOLEAN NOT NULL,
+
+  CONSTRAINT api_tokens_id_unique UNIQUE (id),
+  CONSTRAINT api_tokens_name_unique UNIQUE (name)
+);
\ No newline at end of file
@@ -0,0 +1,24 @@
+CREATE TABLE configs_old (
+	hostname TEXT NOT NULL,
+	config TEXT NOT NULL,
+	version BIGINT NOT NULL DEFAULT 1,
+	updated_ts BIGINT NOT NULL DEFAULT 0,
+
+  CONSTRAINT hostname_unique UNIQUE (hostname)
+);
+INSERT INTO configs_old (hostname, config, version, updated_ts) SELECT hostname, config, version, updated_ts FROM configs;
+DROP TABLE configs;
+ALTER TABLE configs_old RENAME TO configs;
+CREATE TABLE config_revisions_old (
+	hostname TEXT NOT NULL,
+	revision INT NOT NULL,
+	created_ts BIGINT NOT NULL,
+	actor TEXT NOT NULL,
+	action TEXT NOT NULL,
+	config TEXT NULL,
+
+  CONSTRAINT hostname_revision_unique UNIQUE (hostname, revision)
+);
+INSERT INTO config_revisions_old (hostname, revision, created_ts, actor, action, config) SELECT hostname, revision, created_ts, actor, action, config FROM config_revisions;
+DROP TABLE config_revisions;
+ALTER TABLE config_revisions_old RENAME TO config_revisions;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE configs ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE config_revisions ADD COLUMN metadata TEXT NULL;
\ No newline at end of file
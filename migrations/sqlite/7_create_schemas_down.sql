@@ -0,0 +1 @@
+DROP TABLE config_schemas;
\ No newline at end of file
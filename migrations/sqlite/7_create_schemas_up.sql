@@ -0,0 +1,7 @@
+CREATE TABLE config_schemas (
+	name TEXT NOT NULL,
+	schema TEXT NOT NULL,
+	updated_ts BIGINT NOT NULL,
+
+  CONSTRAINT config_schemas_name_unique UNIQUE (name)
+);
\ No newline at end of file
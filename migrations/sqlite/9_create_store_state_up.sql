@@ -0,0 +1,6 @@
+CREATE TABLE store_state (
+	name TEXT NOT NULL,
+	value BIGINT NOT NULL,
+
+  CONSTRAINT store_state_name_unique UNIQUE (name)
+);
\ No newline at end of file
@@ -0,0 +1,26 @@
+package api
+
+import "strings"
+
+// ParseETags parses the value of an If-Match or If-None-Match header. The returned flag is true
+// if the header was "*", which matches any entity. Weak tags are returned with their W/ prefix so
+// that they never compare equal to a strong tag.
+func ParseETags(header string) ([]string, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, false
+	}
+	if header == "*" {
+		return nil, true
+	}
+
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, false
+}
@@ -4,28 +4,66 @@ type ErrorResponse struct {
 	Code         string `json:"errcode"`
 	Message      string `json:"error"`
 	InternalCode string `json:"mr_errcode"`
+
+	// Problems with particular parts of the request body, if known
+	Errors []*FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is a problem with the value at a JSON Pointer (RFC 6901) in a request body
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
 }
 
 type UnknownContentTypeResponse struct {
 	Value interface{}
 }
 
+// RawResponse is sent as-is with the given content type instead of being encoded as JSON
+type RawResponse struct {
+	ContentType string
+	Body        []byte
+}
+
+// NotModifiedResponse is sent without a body in response to a conditional request
+type NotModifiedResponse struct{}
+
 func InternalServerError(message string) *ErrorResponse {
-	return &ErrorResponse{"M_UNKNOWN", message, "M_UNKNOWN"}
+	return &ErrorResponse{Code: "M_UNKNOWN", Message: message, InternalCode: "M_UNKNOWN"}
 }
 
 func NotFoundError() *ErrorResponse {
-	return &ErrorResponse{"M_NOT_FOUND", "Not found", "M_NOT_FOUND"}
+	return &ErrorResponse{Code: "M_NOT_FOUND", Message: "Not found", InternalCode: "M_NOT_FOUND"}
 }
 
 func MethodNotAllowed() *ErrorResponse {
-	return &ErrorResponse{"M_UNKNOWN", "Method Not Allowed", "M_METHOD_NOT_ALLOWED"}
+	return &ErrorResponse{Code: "M_UNKNOWN", Message: "Method Not Allowed", InternalCode: "M_METHOD_NOT_ALLOWED"}
 }
 
 func AuthFailed() *ErrorResponse {
-	return &ErrorResponse{"M_UNKNOWN_TOKEN", "Authentication Failed", "M_UNKNOWN_TOKEN"}
+	return &ErrorResponse{Code: "M_UNKNOWN_TOKEN", Message: "Authentication Failed", InternalCode: "M_UNKNOWN_TOKEN"}
+}
+
+func Forbidden(message string) *ErrorResponse {
+	return &ErrorResponse{Code: "M_FORBIDDEN", Message: message, InternalCode: "M_FORBIDDEN"}
 }
 
 func BadRequest(message string) *ErrorResponse {
-	return &ErrorResponse{"M_UNKNOWN", message, "M_BAD_REQUEST"}
+	return &ErrorResponse{Code: "M_UNKNOWN", Message: message, InternalCode: "M_BAD_REQUEST"}
+}
+
+func NotImplemented(message string) *ErrorResponse {
+	return &ErrorResponse{Code: "M_UNKNOWN", Message: message, InternalCode: "M_NOT_IMPLEMENTED"}
+}
+
+func PreconditionFailed() *ErrorResponse {
+	return &ErrorResponse{Code: "M_UNKNOWN", Message: "Precondition Failed", InternalCode: "M_PRECONDITION_FAILED"}
+}
+
+func Conflict(message string) *ErrorResponse {
+	return &ErrorResponse{Code: "M_UNKNOWN", Message: message, InternalCode: "M_CONFLICT"}
+}
+
+func InvalidConfig(message string, errors []*FieldError) *ErrorResponse {
+	return &ErrorResponse{Code: "M_BAD_JSON", Message: message, InternalCode: "M_BAD_REQUEST", Errors: errors}
 }
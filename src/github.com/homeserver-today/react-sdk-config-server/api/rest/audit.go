@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"net/http"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type AuditResponse struct {
+	Entries []*models.AuditEntry `json:"entries"`
+
+	// The value to give as ?before to get the next (older) page of entries
+	NextBefore *int64 `json:"next_before,omitempty"`
+}
+
+// ListAuditEntries gets the audit log, newest first. The entries can be filtered with ?domain,
+// ?actor, ?since_ts and ?until_ts. Without a domain, the token must be able to read every domain.
+func ListAuditEntries(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	query := r.URL.Query()
+
+	filter := &models.AuditFilter{
+		Domain: query.Get("domain"),
+		Actor:  query.Get("actor"),
+	}
+
+	var errResponse *api.ErrorResponse
+	if filter.SinceTs, errResponse = getInt64Param(r, "since_ts", 0); errResponse != nil {
+		return errResponse
+	}
+	if filter.UntilTs, errResponse = getInt64Param(r, "until_ts", 0); errResponse != nil {
+		return errResponse
+	}
+	if filter.BeforeId, errResponse = getInt64Param(r, "before", 1); errResponse != nil {
+		return errResponse
+	}
+	if filter.Limit, errResponse = getIntParam(r, "limit", defaultListLimit, 1); errResponse != nil {
+		return errResponse
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+
+	domain := filter.Domain
+	if domain == "" {
+		domain = "*"
+	}
+	_, log, errResponse = authorize(r, log, models.PermissionRead, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	entries, err := storage.GetForwardingCache(r.Context(), log).ListAuditEntries(filter)
+	if err == storage.ErrAuditNotSupported {
+		return api.NotImplemented("The audit log is not supported by the storage backend")
+	} else if err != nil {
+		log.Error("Error listing audit entries", err)
+		return api.InternalServerError("Error listing audit entries")
+	}
+
+	response := &AuditResponse{Entries: entries}
+	if len(entries) == filter.Limit {
+		nextBefore := entries[len(entries)-1].Id
+		response.NextBefore = &nextBefore
+	}
+	return response
+}
@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"net/http"
+	"github.com/sirupsen/logrus"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+)
+
+// getPrecondition builds the write precondition described by the If-Match and If-None-Match headers
+func getPrecondition(r *http.Request) (*storage.Precondition) {
+	precondition := &storage.Precondition{}
+	precondition.MatchETags, precondition.MustExist = api.ParseETags(r.Header.Get("If-Match"))
+	precondition.NoMatchETags, precondition.MustNotExist = api.ParseETags(r.Header.Get("If-None-Match"))
+	return precondition
+}
+
+// setStoredETag sends the ETag of the domain's stored config, if it has one
+func setStoredETag(w http.ResponseWriter, r *http.Request, log *logrus.Entry, domain string) {
+	record, err := storage.GetForwardingCache(r.Context(), log).GetStoredConfig(domain)
+	if err == storage.ErrConfigNotFound {
+		return
+	} else if err != nil {
+		log.Error("Error retrieving stored config for ETag", err)
+		return
+	}
+
+	setRecordETag(w, log, record)
+}
+
+// setRecordETag sends the ETag of the stored config
+func setRecordETag(w http.ResponseWriter, log *logrus.Entry, record *storage.ConfigRecord) {
+	etag, err := record.ETag()
+	if err != nil {
+		log.Error("Error calculating ETag", err)
+		return
+	}
+	w.Header().Set("ETag", etag)
+}
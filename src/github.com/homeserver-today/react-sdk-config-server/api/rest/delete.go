@@ -4,15 +4,12 @@ import (
 	"net/http"
 	"github.com/sirupsen/logrus"
 	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
 	"github.com/gorilla/mux"
 	"github.com/homeserver-today/react-sdk-config-server/storage"
 )
 
 func DeleteConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
-	if !isValidApiToken(r, log) {
-		return api.AuthFailed()
-	}
-
 	params := mux.Vars(r)
 
 	domain := params["domain"]
@@ -25,10 +22,14 @@ func DeleteConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) int
 		"domain": domain,
 	})
 
-	newConf, err := storage.GetForwardingCache(r.Context(), log).DeleteConfig(domain)
+	token, log, errResponse := authorize(r, log, models.PermissionDelete, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	newConf, err := storage.GetForwardingCache(r.Context(), log).WithToken(token).WithRemoteAddress(r.RemoteAddr).WithPrecondition(getPrecondition(r)).DeleteConfig(domain)
 	if err != nil {
-		log.Error("Error deleting config", err)
-		return api.InternalServerError("Error deleting config")
+		return writeError(err, log)
 	}
 
 	return newConf
@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// ExportConfigs returns every stored config. NDJSON is returned if requested with ?format=ndjson or
+// the Accept header, otherwise a single JSON document is returned.
+func ExportConfigs(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	_, log, errResponse := authorize(r, log, models.PermissionRead, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	export, err := storage.GetForwardingCache(r.Context(), log).ExportConfigs()
+	if err != nil {
+		log.Error("Error listing configs", err)
+		return api.InternalServerError("Error exporting configs")
+	}
+
+	if r.URL.Query().Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), models.NdjsonContentType) {
+		body, err := export.WriteNdjson()
+		if err != nil {
+			log.Error("Error writing NDJSON", err)
+			return api.InternalServerError("Error exporting configs")
+		}
+		return &api.RawResponse{ContentType: models.NdjsonContentType, Body: body}
+	}
+
+	return export
+}
+
+// ImportConfigs loads an export, either as a JSON document or as NDJSON. The ?mode parameter may be
+// "merge" (the default) or "replace", and ?dry_run=true reports the changes without making them.
+func ImportConfigs(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = models.ImportModeMerge
+	}
+	if mode != models.ImportModeMerge && mode != models.ImportModeReplace {
+		return api.BadRequest("Unknown import mode: " + mode)
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	log = log.WithFields(logrus.Fields{
+		"mode":   mode,
+		"dryRun": dryRun,
+	})
+
+	token, log, errResponse := authenticate(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" && contentType != models.NdjsonContentType {
+		return api.BadRequest("Body not JSON or NDJSON")
+	}
+
+	defer r.Body.Close()
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Error("Failed to read body", err)
+		return api.InternalServerError("Internal Server Error")
+	}
+
+	configs, err := models.ParseConfigExport(contentType, bodyBytes)
+	if err != nil {
+		log.Warn("Failed to parse import: ", err)
+		return api.BadRequest("Invalid import: " + err.Error())
+	}
+
+	// Replacing everything deletes configs we can't know about up front, so the token must be able
+	// to change everything. Merges only need access to the domains being imported.
+	if mode == models.ImportModeReplace {
+		for _, permission := range []string{models.PermissionWrite, models.PermissionDelete} {
+			errResponse = checkAccess(token, log, permission, "*")
+			if errResponse != nil {
+				return errResponse
+			}
+		}
+	} else {
+		for _, c := range configs {
+			errResponse = checkAccess(token, log, models.PermissionWrite, c.Domain)
+			if errResponse != nil {
+				return errResponse
+			}
+		}
+	}
+	log.Info("Authorized import")
+
+	result, err := storage.GetForwardingCache(r.Context(), log).WithToken(token).WithRemoteAddress(r.RemoteAddr).ImportConfigs(configs, mode, dryRun)
+	if err == storage.ErrImportNotSupported {
+		return api.NotImplemented("Importing is not supported by the storage backend")
+	} else if err != nil {
+		return writeError(err, log)
+	}
+
+	return result
+}
@@ -6,13 +6,10 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/homeserver-today/react-sdk-config-server/storage"
 	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
 )
 
 func GetConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
-	if !isValidApiToken(r, log) {
-		return api.AuthFailed()
-	}
-
 	params := mux.Vars(r)
 
 	domain := params["domain"]
@@ -25,6 +22,26 @@ func GetConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interf
 		"domain": domain,
 	})
 
+	_, log, errResponse := authorize(r, log, models.PermissionRead, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	// ?stored=true gets the domain's config without any templates applied, along with the ETag to use
+	// when changing it. The calculated config doesn't have an ETag here, as it isn't what gets changed.
+	if r.URL.Query().Get("stored") == "true" {
+		record, err := storage.GetForwardingCache(r.Context(), log).GetStoredConfig(domain)
+		if err == storage.ErrConfigNotFound {
+			return api.NotFoundError()
+		} else if err != nil {
+			log.Error("Error retrieving stored configuration", err)
+			return api.InternalServerError("Error retrieving config")
+		}
+
+		setRecordETag(w, log, record)
+		return record.Config
+	}
+
 	conf, err := storage.GetForwardingCache(r.Context(), log).GetConfig(domain)
 	if err != nil {
 		log.Error("Error retrieving configuration", err)
@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultListLimit = 100
+const maxListLimit = 1000
+
+type ListConfigsResponse struct {
+	Configs    []*models.ConfigSummary `json:"configs"`
+	Total      int                     `json:"total"`
+	NextOffset *int                    `json:"next_offset,omitempty"`
+}
+
+// ListConfigs lists the domains and globs with stored configs which the token can read
+func ListConfigs(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	query := r.URL.Query()
+
+	filter := &models.ConfigFilter{
+		Type:   query.Get("type"),
+		Prefix: query.Get("prefix"),
+		Search: query.Get("search"),
+		Key:    query.Get("key"),
+	}
+	if filter.Type != "" && filter.Type != models.ConfigTypeGlob && filter.Type != models.ConfigTypeNamed && filter.Type != models.ConfigTypeDomain {
+		return api.BadRequest("Unknown type: " + filter.Type)
+	}
+
+	offset, errResponse := getIntParam(r, "offset", 0, 0)
+	if errResponse != nil {
+		return errResponse
+	}
+	limit, errResponse := getIntParam(r, "limit", defaultListLimit, 1)
+	if errResponse != nil {
+		return errResponse
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	token, log, errResponse := authenticate(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	summaries, err := storage.GetForwardingCache(r.Context(), log).ListConfigs(filter)
+	if err != nil {
+		log.Error("Error listing configs", err)
+		return api.InternalServerError("Error listing configs")
+	}
+
+	// Only show what the token is allowed to see
+	visible := make([]*models.ConfigSummary, 0, len(summaries))
+	for _, s := range summaries {
+		if token.CanAccess(models.PermissionRead, s.Domain) {
+			visible = append(visible, s)
+		}
+	}
+
+	response := &ListConfigsResponse{
+		Configs: make([]*models.ConfigSummary, 0),
+		Total:   len(visible),
+	}
+	if offset < len(visible) {
+		end := offset + limit
+		if end < len(visible) {
+			response.NextOffset = &end
+		} else {
+			end = len(visible)
+		}
+		response.Configs = visible[offset:end]
+	}
+
+	return response
+}
+
+func getIntParam(r *http.Request, name string, defaultValue int, minValue int) (int, *api.ErrorResponse) {
+	str := r.URL.Query().Get(name)
+	if str == "" {
+		return defaultValue, nil
+	}
+
+	val, err := strconv.Atoi(str)
+	if err != nil || val < minValue {
+		return 0, api.BadRequest("Invalid value given for '" + name + "'")
+	}
+	return val, nil
+}
+
+func getInt64Param(r *http.Request, name string, minValue int64) (int64, *api.ErrorResponse) {
+	str := r.URL.Query().Get(name)
+	if str == "" {
+		return 0, nil
+	}
+
+	val, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || val < minValue {
+		return 0, api.BadRequest("Invalid value given for '" + name + "'")
+	}
+	return val, nil
+}
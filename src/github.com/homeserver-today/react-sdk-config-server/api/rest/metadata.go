@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"net/http"
+	"github.com/sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+)
+
+func GetMetadata(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	domain, log, errResponse := getMetadataDomain(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	_, log, errResponse = authorize(r, log, models.PermissionRead, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	record, err := storage.GetForwardingCache(r.Context(), log).GetStoredConfig(domain)
+	if err == storage.ErrConfigNotFound {
+		return api.NotFoundError()
+	} else if err != nil {
+		log.Error("Error retrieving metadata", err)
+		return api.InternalServerError("Error retrieving metadata")
+	}
+
+	setStoredETag(w, r, log, domain)
+	return &record.Metadata
+}
+
+func SetMetadata(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	domain, log, errResponse := getMetadataDomain(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	token, log, errResponse := authorize(r, log, models.PermissionWrite, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	metadata := models.ConfigMetadata{}
+	errResponse = readJsonBody(r, log, &metadata)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	newMetadata, err := storage.GetForwardingCache(r.Context(), log).WithToken(token).WithRemoteAddress(r.RemoteAddr).WithPrecondition(getPrecondition(r)).SetMetadata(domain, metadata)
+	if err == storage.ErrConfigNotFound {
+		return api.NotFoundError()
+	} else if err != nil {
+		return writeError(err, log)
+	}
+
+	setStoredETag(w, r, log, domain)
+	return newMetadata
+}
+
+func getMetadataDomain(r *http.Request, log *logrus.Entry) (string, *logrus.Entry, *api.ErrorResponse) {
+	domain := mux.Vars(r)["domain"]
+	if domain == "" {
+		log.Warn("No domain in request")
+		return "", log, api.BadRequest("No value given for 'domain'")
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"domain": domain,
+	})
+	return domain, log, nil
+}
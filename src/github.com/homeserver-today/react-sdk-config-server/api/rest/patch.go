@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"net/http"
+	"io/ioutil"
+	"mime"
+	"github.com/sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+)
+
+func PatchConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	params := mux.Vars(r)
+
+	domain := params["domain"]
+	if domain == "" {
+		log.Warn("No domain in request")
+		return api.BadRequest("No value given for 'domain'")
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"domain": domain,
+	})
+
+	token, log, errResponse := authorize(r, log, models.PermissionWrite, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return api.BadRequest("Invalid Content-Type")
+	}
+
+	defer r.Body.Close()
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Error("Failed to read body", err)
+		return api.InternalServerError("Internal Server Error")
+	}
+
+	patch, err := models.NewConfigPatch(contentType, bodyBytes)
+	if err == models.ErrUnsupportedPatch {
+		return api.BadRequest("Body must be " + models.MergePatchContentType + " or " + models.JsonPatchContentType)
+	} else if err != nil {
+		log.Warn("Failed to parse patch: ", err)
+		return api.BadRequest(err.Error())
+	}
+
+	newConf, err := storage.GetForwardingCache(r.Context(), log).WithToken(token).WithRemoteAddress(r.RemoteAddr).WithPrecondition(getPrecondition(r)).PatchConfig(domain, patch)
+	if patchErr, ok := err.(*models.PatchError); ok {
+		log.Warn("Failed to apply patch: ", patchErr)
+		return api.BadRequest(patchErr.Error())
+	} else if err != nil {
+		return writeError(err, log)
+	}
+
+	setStoredETag(w, r, log, domain)
+	return newConf
+}
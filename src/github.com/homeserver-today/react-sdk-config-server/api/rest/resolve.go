@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"net/http"
+	"github.com/gorilla/mux"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type ResolveResponse struct {
+	Domain string             `json:"domain"`
+	Config models.ReactConfig `json:"config"`
+
+	// Templates with the same weight which set different values for a key
+	Warnings []*models.TemplateConflict `json:"warnings,omitempty"`
+}
+
+type ExplainResponse struct {
+	ResolveResponse
+	Sources   map[string]interface{} `json:"sources"`
+	Templates []*ResolvedTemplate    `json:"templates"`
+
+	// Keys removed by a $unset list, with the config which removed them
+	Unset map[string]string `json:"unset,omitempty"`
+}
+
+type ResolvedTemplate struct {
+	Domain string `json:"domain"`
+	Weight int    `json:"weight"`
+}
+
+// ResolveConfig calculates the complete config for the domain, warning about any conflicting templates.
+// With ?explain=true, the source of every value and the templates which were used (least important
+// first) are included.
+func ResolveConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	params := mux.Vars(r)
+
+	domain := params["domain"]
+	if domain == "" {
+		log.Warn("No domain in request")
+		return api.BadRequest("No value given for 'domain'")
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"domain": domain,
+	})
+
+	_, log, errResponse := authorize(r, log, models.PermissionRead, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	resolution, err := storage.GetForwardingCache(r.Context(), log).ExplainConfig(domain)
+	if err != nil {
+		log.Error("Error resolving configuration", err)
+		return api.InternalServerError("Error retrieving config")
+	}
+
+	if r.URL.Query().Get("explain") != "true" {
+		return &ResolveResponse{Domain: domain, Config: resolution.Config, Warnings: resolution.Conflicts}
+	}
+	return NewExplainResponse(domain, resolution)
+}
+
+func NewExplainResponse(domain string, resolution *models.Resolution) (*ExplainResponse) {
+	response := &ExplainResponse{
+		ResolveResponse: ResolveResponse{Domain: domain, Config: resolution.Config, Warnings: resolution.Conflicts},
+		Sources:         resolution.Sources,
+		Templates:       make([]*ResolvedTemplate, 0),
+		Unset:           resolution.Unset,
+	}
+	for _, layer := range resolution.Layers {
+		if layer.Source != domain {
+			response.Templates = append(response.Templates, &ResolvedTemplate{Domain: layer.Source, Weight: layer.Weight})
+		}
+	}
+
+	return response
+}
@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"github.com/sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+type RevisionsResponse struct {
+	Revisions []*models.ConfigRevision `json:"revisions"`
+}
+
+func ListRevisions(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	params := mux.Vars(r)
+
+	domain := params["domain"]
+	if domain == "" {
+		log.Warn("No domain in request")
+		return api.BadRequest("No value given for 'domain'")
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"domain": domain,
+	})
+
+	_, log, errResponse := authorize(r, log, models.PermissionRead, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	revisions, err := storage.GetForwardingCache(r.Context(), log).ListRevisions(domain)
+	if err == storage.ErrRevisionsNotSupported {
+		return api.NotImplemented("Revision history is not supported by the storage backend")
+	} else if err != nil {
+		log.Error("Error listing revisions", err)
+		return api.InternalServerError("Error listing revisions")
+	}
+
+	return &RevisionsResponse{Revisions: revisions}
+}
+
+func GetRevision(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	domain, revisionNumber, errResponse := getRevisionParams(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"domain":   domain,
+		"revision": revisionNumber,
+	})
+
+	_, log, errResponse = authorize(r, log, models.PermissionRead, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	revision, err := storage.GetForwardingCache(r.Context(), log).GetRevision(domain, revisionNumber)
+	if err == storage.ErrRevisionsNotSupported {
+		return api.NotImplemented("Revision history is not supported by the storage backend")
+	} else if err == storage.ErrRevisionNotFound {
+		return api.NotFoundError()
+	} else if err != nil {
+		log.Error("Error retrieving revision", err)
+		return api.InternalServerError("Error retrieving revision")
+	}
+
+	return revision
+}
+
+func RollbackConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	domain, revisionNumber, errResponse := getRevisionParams(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"domain":   domain,
+		"revision": revisionNumber,
+	})
+
+	token, log, errResponse := authorize(r, log, models.PermissionWrite, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	// Rolling back to a deletion deletes the config, which the token must also be allowed to do
+	revision, err := storage.GetForwardingCache(r.Context(), log).GetRevision(domain, revisionNumber)
+	if err == nil && revision.Action == models.RevisionActionDelete && !token.CanAccess(models.PermissionDelete, domain) {
+		log.Warn("Token does not have delete permission for " + domain)
+		return api.Forbidden("Token cannot delete " + domain)
+	}
+
+	newConf, err := storage.GetForwardingCache(r.Context(), log).WithToken(token).WithRemoteAddress(r.RemoteAddr).WithPrecondition(getPrecondition(r)).RollbackConfig(domain, revisionNumber)
+	if err == storage.ErrRevisionsNotSupported {
+		return api.NotImplemented("Revision history is not supported by the storage backend")
+	} else if err == storage.ErrRevisionNotFound {
+		return api.NotFoundError()
+	} else if err != nil {
+		return writeError(err, log)
+	}
+
+	setStoredETag(w, r, log, domain)
+	return newConf
+}
+
+func getRevisionParams(r *http.Request, log *logrus.Entry) (string, int, *api.ErrorResponse) {
+	params := mux.Vars(r)
+
+	domain := params["domain"]
+	if domain == "" {
+		log.Warn("No domain in request")
+		return "", 0, api.BadRequest("No value given for 'domain'")
+	}
+
+	revisionNumber, err := strconv.Atoi(params["revision"])
+	if err != nil {
+		log.Warn("Invalid revision in request")
+		return "", 0, api.BadRequest("Invalid value given for 'revision'")
+	}
+
+	return domain, revisionNumber, nil
+}
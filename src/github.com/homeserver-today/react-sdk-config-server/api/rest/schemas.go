@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"github.com/gorilla/mux"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type SchemasResponse struct {
+	Schemas []*models.StoredSchema `json:"schemas"`
+}
+
+func ListSchemas(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	_, log, errResponse := authorize(r, log, models.PermissionAdmin, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	schemas, err := storage.ListSchemas(r.Context())
+	if err != nil {
+		log.Error("Error listing schemas", err)
+		return api.InternalServerError("Error listing schemas")
+	}
+
+	return &SchemasResponse{Schemas: schemas}
+}
+
+func GetSchema(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	name, log, errResponse := getSchemaName(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	_, log, errResponse = authorize(r, log, models.PermissionAdmin, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	schema, err := storage.GetSchema(r.Context(), name)
+	if err == storage.ErrSchemaNotFound {
+		return api.NotFoundError()
+	} else if err != nil {
+		log.Error("Error retrieving schema", err)
+		return api.InternalServerError("Error retrieving schema")
+	}
+
+	return schema
+}
+
+func SetSchema(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	name, log, errResponse := getSchemaName(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	_, log, errResponse = authorize(r, log, models.PermissionAdmin, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	schema := json.RawMessage{}
+	errResponse = readJsonBody(r, log, &schema)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	stored, err := storage.SetSchema(r.Context(), name, schema)
+	if err == storage.ErrSchemasNotSupported {
+		return api.NotImplemented("Schemas are not supported by the storage backend")
+	} else if validationErr, ok := err.(*models.ValidationError); ok {
+		log.Warn("Invalid schema: ", validationErr)
+		return api.BadRequest(validationErr.Error())
+	} else if err != nil {
+		log.Error("Error updating schema", err)
+		return api.InternalServerError("Error updating schema")
+	}
+
+	log.Info("Updated schema")
+	return stored
+}
+
+func DeleteSchema(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	name, log, errResponse := getSchemaName(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	_, log, errResponse = authorize(r, log, models.PermissionAdmin, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	schema, err := storage.GetSchema(r.Context(), name)
+	if err == nil {
+		err = storage.DeleteSchema(r.Context(), name)
+	}
+	if err == storage.ErrSchemasNotSupported {
+		return api.NotImplemented("Schemas are not supported by the storage backend")
+	} else if err == storage.ErrSchemaNotFound {
+		return api.NotFoundError()
+	} else if validationErr, ok := err.(*models.ValidationError); ok {
+		log.Warn("Cannot delete schema: ", validationErr)
+		return api.Conflict(validationErr.Error())
+	} else if err != nil {
+		log.Error("Error deleting schema", err)
+		return api.InternalServerError("Error deleting schema")
+	}
+
+	log.Info("Deleted schema")
+	return schema
+}
+
+func getSchemaName(r *http.Request, log *logrus.Entry) (string, *logrus.Entry, *api.ErrorResponse) {
+	name := mux.Vars(r)["name"]
+	if name == "" {
+		log.Warn("No schema name in request")
+		return "", log, api.BadRequest("No value given for 'name'")
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"schema": name,
+	})
+	return name, log, nil
+}
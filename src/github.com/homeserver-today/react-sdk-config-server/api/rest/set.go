@@ -12,8 +12,21 @@ import (
 )
 
 func SetConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
-	if !isValidApiToken(r, log) {
-		return api.AuthFailed()
+	params := mux.Vars(r)
+
+	domain := params["domain"]
+	if domain == "" {
+		log.Warn("No domain in request")
+		return api.BadRequest("No value given for 'domain'")
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"domain": domain,
+	})
+
+	token, log, errResponse := authorize(r, log, models.PermissionWrite, domain)
+	if errResponse != nil {
+		return errResponse
 	}
 
 	contentType := r.Header.Get("Content-Type")
@@ -35,23 +48,11 @@ func SetConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interf
 		return api.BadRequest("Body not JSON")
 	}
 
-	params := mux.Vars(r)
-
-	domain := params["domain"]
-	if domain == "" {
-		log.Warn("No domain in request")
-		return api.BadRequest("No value given for 'domain'")
-	}
-
-	log = log.WithFields(logrus.Fields{
-		"domain": domain,
-	})
-
-	newConf, err := storage.GetForwardingCache(r.Context(), log).SetConfig(domain, &newConfig)
+	newConf, err := storage.GetForwardingCache(r.Context(), log).WithToken(token).WithRemoteAddress(r.RemoteAddr).WithPrecondition(getPrecondition(r)).SetConfig(domain, &newConfig)
 	if err != nil {
-		log.Error("Error updating config", err)
-		return api.InternalServerError("Error updating config")
+		return writeError(err, log)
 	}
 
+	setStoredETag(w, r, log, domain)
 	return newConf
 }
@@ -1,31 +1,112 @@
 package rest
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"github.com/homeserver-today/react-sdk-config-server/api"
 	"github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
 	"github.com/sirupsen/logrus"
 	"strings"
 )
 
-func isValidApiToken(r *http.Request, log *logrus.Entry) (bool) {
-	requiredToken := config.Get().ApiConfig.SharedSecret
-	if requiredToken == config.DefaultSharedSecret {
-		log.Warn("Your API token is set to the default value. Please change this to enable requests.")
-		return false
+// The name of the token which uses the shared secret. The shared secret may access everything.
+const sharedSecretTokenName = "shared_secret"
+
+// authorize checks that the request's token may perform the action on the domain. The returned log
+// entry identifies the token used.
+func authorize(r *http.Request, log *logrus.Entry, permission string, domain string) (*models.ApiToken, *logrus.Entry, *api.ErrorResponse) {
+	token, log, errResponse := authenticate(r, log)
+	if errResponse != nil {
+		return nil, log, errResponse
+	}
+
+	errResponse = checkAccess(token, log, permission, domain)
+	if errResponse != nil {
+		return nil, log, errResponse
 	}
 
+	log.Info("Authorized request (" + permission + ")")
+	return token, log, nil
+}
+
+// authenticate finds the request's token without checking what it may access. Callers must use
+// checkAccess before doing anything with the token.
+func authenticate(r *http.Request, log *logrus.Entry) (*models.ApiToken, *logrus.Entry, *api.ErrorResponse) {
 	sentToken := r.Header.Get("Authorization")
 	if !strings.HasPrefix(sentToken, "Bearer ") {
 		log.Warn("Authorization header is not a Bearer Token")
-		return false
+		return nil, log, api.AuthFailed()
 	}
-
 	sentToken = sentToken[len("Bearer "):]
-	if sentToken != requiredToken {
+
+	token := findApiToken(r, sentToken, log)
+	if token == nil {
 		log.Warn("Token does not match configuration")
-		return false
+		return nil, log, api.AuthFailed()
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"token": token.Name,
+	})
+	return token, log, nil
+}
+
+func checkAccess(token *models.ApiToken, log *logrus.Entry, permission string, domain string) (*api.ErrorResponse) {
+	if !token.CanAccess(permission, domain) {
+		log.Warn("Token does not have " + permission + " permission for " + domain)
+		return api.Forbidden("Token cannot " + permission + " " + domain)
+	}
+	return nil
+}
+
+func findApiToken(r *http.Request, sentToken string, log *logrus.Entry) (*models.ApiToken) {
+	apiConfig := config.Get().ApiConfig
+
+	if apiConfig.SharedSecret == config.DefaultSharedSecret {
+		if len(apiConfig.Tokens) == 0 {
+			log.Warn("Your API token is set to the default value. Please change this to enable requests.")
+		}
+	} else if tokensMatch(sentToken, apiConfig.SharedSecret) {
+		return &models.ApiToken{
+			Name:        sharedSecretTokenName,
+			Domains:     []string{"*"},
+			Permissions: models.AllPermissions,
+		}
+	}
+
+	for _, t := range apiConfig.Tokens {
+		if t.Token != "" && tokensMatch(sentToken, t.Token) {
+			return &models.ApiToken{
+				Name:        t.Name,
+				Domains:     t.Domains,
+				Permissions: t.Permissions,
+			}
+		}
+	}
+
+	token, err := storage.FindApiToken(r.Context(), sentToken)
+	if err != nil {
+		log.Error("Error looking up stored token: ", err)
+		return nil
+	}
+	return token
+}
+
+// isConfiguredTokenName determines if the name belongs to a token which is not managed through the API
+func isConfiguredTokenName(name string) (bool) {
+	if name == sharedSecretTokenName {
+		return true
+	}
+	for _, t := range config.Get().ApiConfig.Tokens {
+		if t.Name == name {
+			return true
+		}
 	}
+	return false
+}
 
-	log.Info("Authorized request (tokens match)")
-	return true
+func tokensMatch(sentToken string, expectedToken string) (bool) {
+	return subtle.ConstantTimeCompare([]byte(sentToken), []byte(expectedToken)) == 1
 }
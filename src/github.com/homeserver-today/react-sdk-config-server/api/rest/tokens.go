@@ -0,0 +1,228 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"github.com/gorilla/mux"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+	"github.com/sirupsen/logrus"
+)
+
+type TokensResponse struct {
+	Tokens []*models.StoredApiToken `json:"tokens"`
+}
+
+type CreateTokenRequest struct {
+	Name        string   `json:"name"`
+	Domains     []string `json:"domains"`
+	Permissions []string `json:"permissions"`
+	ExpiresTs   int64    `json:"expires_ts"`
+}
+
+type CreateTokenResponse struct {
+	*models.StoredApiToken
+	Token string `json:"token"`
+}
+
+type ExpireTokenRequest struct {
+	ExpiresTs *int64 `json:"expires_ts"`
+}
+
+func ListTokens(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	_, log, errResponse := authorize(r, log, models.PermissionAdmin, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	tokens, err := storage.ListApiTokens(r.Context())
+	if err == storage.ErrTokensNotSupported {
+		return api.NotImplemented("API tokens are not supported by the storage backend")
+	} else if err != nil {
+		log.Error("Error listing tokens", err)
+		return api.InternalServerError("Error listing tokens")
+	}
+
+	return &TokensResponse{Tokens: tokens}
+}
+
+func CreateToken(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	_, log, errResponse := authorize(r, log, models.PermissionAdmin, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	request := &CreateTokenRequest{}
+	errResponse = readJsonBody(r, log, request)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if request.Name == "" {
+		return api.BadRequest("No value given for 'name'")
+	}
+	if isConfiguredTokenName(request.Name) {
+		return api.Conflict("A token named " + request.Name + " is in the server's configuration")
+	}
+	if len(request.Domains) == 0 {
+		return api.BadRequest("No value given for 'domains'")
+	}
+	if len(request.Permissions) == 0 {
+		return api.BadRequest("No value given for 'permissions'")
+	}
+	for _, p := range request.Permissions {
+		if !models.IsValidPermission(p) {
+			return api.BadRequest("Unknown permission: " + p)
+		}
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"newToken": request.Name,
+	})
+
+	token := models.ApiToken{
+		Name:        request.Name,
+		Domains:     request.Domains,
+		Permissions: request.Permissions,
+	}
+	stored, plaintext, err := storage.CreateApiToken(r.Context(), token, request.ExpiresTs)
+	if err == storage.ErrTokensNotSupported {
+		return api.NotImplemented("API tokens are not supported by the storage backend")
+	} else if err == storage.ErrTokenExists {
+		return api.Conflict("A token named " + request.Name + " already exists")
+	} else if err != nil {
+		log.Error("Error creating token", err)
+		return api.InternalServerError("Error creating token")
+	}
+
+	log.Info("Created token")
+	return &CreateTokenResponse{StoredApiToken: stored, Token: plaintext}
+}
+
+func RevokeToken(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	name, log, errResponse := getTokenName(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	_, log, errResponse = authorize(r, log, models.PermissionAdmin, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	token, err := storage.RevokeApiToken(r.Context(), name)
+	errResponse = tokenUpdateError(err, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	log.Info("Revoked token")
+	return token
+}
+
+func ExpireToken(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	name, log, errResponse := getTokenName(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	_, log, errResponse = authorize(r, log, models.PermissionAdmin, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	// An empty body expires the token immediately
+	request := &ExpireTokenRequest{}
+	if r.ContentLength != 0 {
+		errResponse = readJsonBody(r, log, request)
+		if errResponse != nil {
+			return errResponse
+		}
+	}
+	expiresTs := util.NowMillis()
+	if request.ExpiresTs != nil {
+		expiresTs = *request.ExpiresTs
+	}
+
+	token, err := storage.ExpireApiToken(r.Context(), name, expiresTs)
+	errResponse = tokenUpdateError(err, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	log.Info("Set token expiry")
+	return token
+}
+
+func getTokenName(r *http.Request, log *logrus.Entry) (string, *logrus.Entry, *api.ErrorResponse) {
+	name := mux.Vars(r)["name"]
+	if name == "" {
+		log.Warn("No token name in request")
+		return "", log, api.BadRequest("No value given for 'name'")
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"targetToken": name,
+	})
+	return name, log, nil
+}
+
+func tokenUpdateError(err error, log *logrus.Entry) (*api.ErrorResponse) {
+	if err == nil {
+		return nil
+	} else if err == storage.ErrTokensNotSupported {
+		return api.NotImplemented("API tokens are not supported by the storage backend")
+	} else if err == storage.ErrTokenNotFound {
+		return api.NotFoundError()
+	}
+
+	log.Error("Error updating token", err)
+	return api.InternalServerError("Error updating token")
+}
+
+// writeError describes why a change to configs was refused. Callers deal with the errors particular
+// to their request before handing the rest to this.
+func writeError(err error, log *logrus.Entry) (*api.ErrorResponse) {
+	if err == nil {
+		return nil
+	} else if schemaErr, ok := err.(*models.SchemaValidationError); ok {
+		log.Warn("Config does not match schema: ", schemaErr)
+		return invalidConfig(schemaErr)
+	} else if forbiddenErr, ok := err.(*models.ForbiddenError); ok {
+		log.Warn(forbiddenErr.Error())
+		return api.Forbidden(forbiddenErr.Error())
+	} else if validationErr, ok := err.(*models.ValidationError); ok {
+		log.Warn("Invalid change: ", validationErr)
+		return api.BadRequest(validationErr.Error())
+	} else if err == storage.ErrPreconditionFailed {
+		log.Warn("Precondition failed for change")
+		return api.PreconditionFailed()
+	}
+
+	log.Error("Error changing configs", err)
+	return api.InternalServerError("Error changing configs")
+}
+
+func readJsonBody(r *http.Request, log *logrus.Entry, into interface{}) (*api.ErrorResponse) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		return api.BadRequest("Body not JSON")
+	}
+
+	defer r.Body.Close()
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Error("Failed to read body", err)
+		return api.InternalServerError("Internal Server Error")
+	}
+
+	err = json.Unmarshal(bodyBytes, into)
+	if err != nil {
+		log.Error("Failed to parse body as JSON", err)
+		return api.BadRequest("Body not JSON")
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"net/http"
+	"github.com/gorilla/mux"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// ValidateConfig reports whether the body would be accepted as the domain's config, without storing
+// it. The config is checked against the domain's schema (or the one named by ?schema) even if
+// validation is not enabled.
+func ValidateConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	domain, schemaName, log, errResponse := getValidateParams(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	_, log, errResponse = authorize(r, log, models.PermissionWrite, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	config := models.ReactConfig{}
+	errResponse = readJsonBody(r, log, &config)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	result, err := storage.GetForwardingCache(r.Context(), log).ValidateConfig(domain, config, schemaName)
+	return validationResponse(result, err, schemaName, log)
+}
+
+// ValidateResolvedConfig reports whether the complete config for the domain matches the domain's
+// schema (or the one named by ?schema).
+func ValidateResolvedConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	domain, schemaName, log, errResponse := getValidateParams(r, log)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	_, log, errResponse = authorize(r, log, models.PermissionRead, domain)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if schemaName == "" {
+		return api.BadRequest("No schema is configured for " + domain)
+	}
+
+	result, err := storage.GetForwardingCache(r.Context(), log).ValidateResolvedConfig(domain, schemaName)
+	return validationResponse(result, err, schemaName, log)
+}
+
+func getValidateParams(r *http.Request, log *logrus.Entry) (string, string, *logrus.Entry, *api.ErrorResponse) {
+	domain := mux.Vars(r)["domain"]
+	if domain == "" {
+		log.Warn("No domain in request")
+		return "", "", log, api.BadRequest("No value given for 'domain'")
+	}
+
+	schemaName := r.URL.Query().Get("schema")
+	if schemaName == "" {
+		schemaName = storage.SchemaNameForDomain(domain)
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"domain": domain,
+		"schema": schemaName,
+	})
+	return domain, schemaName, log, nil
+}
+
+func validationResponse(result *models.ValidationResult, err error, schemaName string, log *logrus.Entry) interface{} {
+	if err == storage.ErrSchemaNotFound {
+		return api.BadRequest("Unknown schema: " + schemaName)
+	} else if err != nil {
+		log.Error("Error validating config", err)
+		return api.InternalServerError("Error validating config")
+	}
+	return result
+}
+
+// invalidConfig describes where a config does not match its schema
+func invalidConfig(err *models.SchemaValidationError) (*api.ErrorResponse) {
+	fieldErrors := make([]*api.FieldError, 0, len(err.Errors))
+	for _, e := range err.Errors {
+		fieldErrors = append(fieldErrors, &api.FieldError{Path: e.Path, Message: e.Message})
+	}
+	return api.InvalidConfig(err.Error(), fieldErrors)
+}
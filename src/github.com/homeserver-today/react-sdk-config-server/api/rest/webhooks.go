@@ -0,0 +1,22 @@
+package rest
+
+import (
+	"net/http"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/webhooks"
+	"github.com/sirupsen/logrus"
+)
+
+type DeliveriesResponse struct {
+	Deliveries []*models.WebhookDelivery `json:"deliveries"`
+}
+
+// ListWebhookDeliveries gets the most recent webhook deliveries made by this instance, newest first
+func ListWebhookDeliveries(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
+	_, log, errResponse := authorize(r, log, models.PermissionAdmin, "*")
+	if errResponse != nil {
+		return errResponse
+	}
+
+	return &DeliveriesResponse{Deliveries: webhooks.GetDispatcher().Deliveries()}
+}
@@ -2,10 +2,13 @@ package serve
 
 import (
 	"net/http"
+	"time"
 	"github.com/sirupsen/logrus"
 	"github.com/gorilla/mux"
+	"github.com/ryanuber/go-glob"
 	"github.com/homeserver-today/react-sdk-config-server/storage"
 	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/config"
 )
 
 func GetConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interface{} {
@@ -21,12 +24,62 @@ func GetConfig(w http.ResponseWriter, r *http.Request, log *logrus.Entry) interf
 		"domain": domain,
 	})
 
-	conf, err := storage.GetForwardingCache(r.Context(), log).GetConfig(domain)
+	resolved, err := storage.GetForwardingCache(r.Context(), log).GetResolvedConfig(domain)
 	if err != nil {
 		log.Error("Error retrieving configuration", err)
 		return api.InternalServerError("Error retrieving config")
 	}
 
+	w.Header().Set("Cache-Control", getCacheControl(domain))
+	w.Header().Set("ETag", resolved.ETag)
+	if resolved.LastModified > 0 {
+		w.Header().Set("Last-Modified", time.Unix(0, resolved.LastModified*int64(time.Millisecond)).UTC().Format(http.TimeFormat))
+	}
+
+	if isNotModified(r, resolved) {
+		return &api.NotModifiedResponse{}
+	}
+
 	// No errors, so return the config as-is
-	return conf
+	return resolved.Config
+}
+
+func getCacheControl(domain string) (string) {
+	serveConfig := config.Get().Serve
+	for _, d := range serveConfig.DomainCacheControl {
+		if glob.Glob(d.Domain, domain) {
+			return d.CacheControl
+		}
+	}
+
+	return serveConfig.CacheControl
+}
+
+func isNotModified(r *http.Request, resolved *storage.ResolvedConfig) (bool) {
+	// If-None-Match takes precedence over If-Modified-Since, and uses the weak comparison
+	if r.Header.Get("If-None-Match") != "" {
+		etags, matchesAny := api.ParseETags(r.Header.Get("If-None-Match"))
+		if matchesAny {
+			return true
+		}
+		for _, etag := range etags {
+			if etag == resolved.ETag || etag == "W/"+resolved.ETag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if resolved.LastModified > 0 && r.Header.Get("If-Modified-Since") != "" {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil {
+			return false
+		}
+
+		// HTTP dates only have a resolution of seconds
+		lastModified := time.Unix(0, resolved.LastModified*int64(time.Millisecond)).Truncate(time.Second)
+		return !lastModified.After(since)
+	}
+
+	return false
 }
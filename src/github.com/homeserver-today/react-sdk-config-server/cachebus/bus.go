@@ -0,0 +1,60 @@
+package cachebus
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Event tells other servers that what they have cached for a domain is out of date
+type Event struct {
+	// The server which published the event. Servers ignore their own events. Events published by the
+	// database have no origin, so reach every server.
+	Origin string `json:"origin"`
+
+	Domain string `json:"domain,omitempty"`
+
+	// When set, everything cached is out of date
+	Flush bool `json:"flush,omitempty"`
+}
+
+// Handler is called with the events published by other servers
+type Handler func(event *Event)
+
+// Bus carries cache invalidations between servers which share the same storage
+type Bus interface {
+	// Publish sends the event to every other server subscribed to the bus
+	Publish(event *Event) (error)
+
+	// Subscribe starts calling the handler (in the background) for events published by other servers
+	Subscribe(handler Handler)
+
+	Close() (error)
+}
+
+// localBus is used when there is only one server, so there is nobody to tell about changes
+type localBus struct{}
+
+func NewLocalBus() (Bus) {
+	return &localBus{}
+}
+
+func (b *localBus) Publish(event *Event) (error) {
+	return nil
+}
+
+func (b *localBus) Subscribe(handler Handler) {
+}
+
+func (b *localBus) Close() (error) {
+	return nil
+}
+
+// newOrigin creates a random name for this server
+func newOrigin() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,147 @@
+package cachebus
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+const minReconnectInterval = 10 * time.Second
+const maxReconnectInterval = 1 * time.Minute
+
+// The listener's connection is checked this often when nothing has been received
+const pingInterval = 90 * time.Second
+
+const notifyStatement = "SELECT pg_notify($1, $2);"
+const registerChannelStatement = "INSERT INTO cache_bus_channels (channel) VALUES ($1) ON CONFLICT (channel) DO NOTHING;"
+
+// postgresBus uses Postgres's LISTEN and NOTIFY to send events between servers
+type postgresBus struct {
+	db       *sql.DB
+	listener *pq.Listener
+	channel  string
+	origin   string
+	log      *logrus.Entry
+
+	// When set, the database publishes an event whenever a config changes
+	publishedByDatabase bool
+}
+
+// NewPostgresBus connects to the Postgres database and starts listening on the channel. The servers
+// sharing a bus must all use the same database and channel. When the configs are stored in the same
+// database, publishedByDatabase should be set: the channel is registered with the database, which
+// then publishes an event in the same transaction as every change to a config (including changes
+// made without the server), and Publish does nothing.
+func NewPostgresBus(connectionString string, channel string, publishedByDatabase bool) (Bus, error) {
+	origin, err := newOrigin()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	b := &postgresBus{
+		db:                  db,
+		channel:             channel,
+		origin:              origin,
+		log:                 logrus.WithFields(logrus.Fields{"cacheBus": channel}),
+		publishedByDatabase: publishedByDatabase,
+	}
+	if publishedByDatabase {
+		_, err = db.Exec(registerChannelStatement, channel)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	b.listener = pq.NewListener(connectionString, minReconnectInterval, maxReconnectInterval, b.onListenerEvent)
+	err = b.listener.Listen(channel)
+	if err != nil {
+		b.listener.Close()
+		db.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *postgresBus) Publish(event *Event) (error) {
+	if b.publishedByDatabase {
+		return nil
+	}
+
+	event.Origin = b.origin
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.db.Exec(notifyStatement, b.channel, string(payload))
+	return err
+}
+
+func (b *postgresBus) Subscribe(handler Handler) {
+	go func() {
+		for {
+			select {
+			case notification, ok := <-b.listener.Notify:
+				if !ok {
+					return
+				}
+
+				if notification == nil {
+					// The listener reconnected, so we may have missed events while it was disconnected
+					handler(&Event{Flush: true})
+					continue
+				}
+
+				event := &Event{}
+				err := json.Unmarshal([]byte(notification.Extra), event)
+				if err != nil {
+					b.log.Error("Ignoring invalid cache event: ", err)
+					continue
+				}
+				if event.Origin == b.origin {
+					continue
+				}
+				handler(event)
+				break
+			case <-time.After(pingInterval):
+				go b.listener.Ping()
+				break
+			}
+		}
+	}()
+}
+
+func (b *postgresBus) Close() (error) {
+	err := b.listener.Close()
+	if err != nil {
+		return err
+	}
+	return b.db.Close()
+}
+
+func (b *postgresBus) onListenerEvent(eventType pq.ListenerEventType, err error) {
+	switch eventType {
+	case pq.ListenerEventDisconnected:
+		b.log.Warn("Lost connection to the cache bus: ", err)
+		break
+	case pq.ListenerEventReconnected:
+		b.log.Info("Reconnected to the cache bus")
+		break
+	case pq.ListenerEventConnectionAttemptFailed:
+		b.log.Error("Failed to reconnect to the cache bus: ", err)
+		break
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/homeserver-today/react-sdk-config-server/models"
 	"fmt"
 	"github.com/homeserver-today/react-sdk-config-server/metrics"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
 )
 
 const UnkErrJson = `{"code":"M_UNKNOWN","message":"Unexpected error processing response"}`
@@ -64,6 +65,17 @@ func main() {
 
 	logrus.Info("Starting config server...")
 
+	logrus.Info("Opening storage (" + config.Get().Database.Driver + ")...")
+	err = storage.OpenStore()
+	if err != nil {
+		logrus.Fatal("Failed to open storage: ", err)
+	}
+
+	err = storage.OpenCacheBus()
+	if err != nil {
+		logrus.Fatal("Failed to open cache bus: ", err)
+	}
+
 	counter := requestCounter{}
 	hOpts := HandlerOpts{&counter}
 
@@ -71,14 +83,58 @@ func main() {
 	serveConfigHandler := Handler{serve.GetConfig, hOpts, "ServeConfig"}
 	getConfigHandler := Handler{rest.GetConfig, hOpts, "GetConfig"}
 	setConfigHandler := Handler{rest.SetConfig, hOpts, "SetConfig"}
+	patchConfigHandler := Handler{rest.PatchConfig, hOpts, "PatchConfig"}
 	deleteConfigHandler := Handler{rest.DeleteConfig, hOpts, "DeleteConfig"}
+	listRevisionsHandler := Handler{rest.ListRevisions, hOpts, "ListRevisions"}
+	getRevisionHandler := Handler{rest.GetRevision, hOpts, "GetRevision"}
+	rollbackConfigHandler := Handler{rest.RollbackConfig, hOpts, "RollbackConfig"}
+	resolveConfigHandler := Handler{rest.ResolveConfig, hOpts, "ResolveConfig"}
+	listConfigsHandler := Handler{rest.ListConfigs, hOpts, "ListConfigs"}
+	getMetadataHandler := Handler{rest.GetMetadata, hOpts, "GetMetadata"}
+	setMetadataHandler := Handler{rest.SetMetadata, hOpts, "SetMetadata"}
+	exportConfigsHandler := Handler{rest.ExportConfigs, hOpts, "ExportConfigs"}
+	importConfigsHandler := Handler{rest.ImportConfigs, hOpts, "ImportConfigs"}
+	listTokensHandler := Handler{rest.ListTokens, hOpts, "ListTokens"}
+	createTokenHandler := Handler{rest.CreateToken, hOpts, "CreateToken"}
+	revokeTokenHandler := Handler{rest.RevokeToken, hOpts, "RevokeToken"}
+	expireTokenHandler := Handler{rest.ExpireToken, hOpts, "ExpireToken"}
+	listSchemasHandler := Handler{rest.ListSchemas, hOpts, "ListSchemas"}
+	getSchemaHandler := Handler{rest.GetSchema, hOpts, "GetSchema"}
+	setSchemaHandler := Handler{rest.SetSchema, hOpts, "SetSchema"}
+	deleteSchemaHandler := Handler{rest.DeleteSchema, hOpts, "DeleteSchema"}
+	validateConfigHandler := Handler{rest.ValidateConfig, hOpts, "ValidateConfig"}
+	validateResolvedConfigHandler := Handler{rest.ValidateResolvedConfig, hOpts, "ValidateResolvedConfig"}
+	listAuditEntriesHandler := Handler{rest.ListAuditEntries, hOpts, "ListAuditEntries"}
+	listWebhookDeliveriesHandler := Handler{rest.ListWebhookDeliveries, hOpts, "ListWebhookDeliveries"}
 
 	routes := list.New()
 	routes.PushBack(&ApiRoute{"/config.{domain:.*}.json", "GET", serveConfigHandler})
 	routes.PushBack(&ApiRoute{"/config.json", "GET", serveConfigHandler})
 	routes.PushBack(&ApiRoute{"/api/v1/config/{domain:.*}", "GET", getConfigHandler})
 	routes.PushBack(&ApiRoute{"/api/v1/config/{domain:.*}", "PUT", setConfigHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/config/{domain:.*}", "PATCH", patchConfigHandler})
 	routes.PushBack(&ApiRoute{"/api/v1/config/{domain:.*}", "DELETE", deleteConfigHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/resolve/{domain:.*}", "GET", resolveConfigHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/configs", "GET", listConfigsHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/metadata/{domain:.*}", "GET", getMetadataHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/metadata/{domain:.*}", "PUT", setMetadataHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/revisions/{domain}", "GET", listRevisionsHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/revisions/{domain}/{revision:[0-9]+}", "GET", getRevisionHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/revisions/{domain}/{revision:[0-9]+}/rollback", "POST", rollbackConfigHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/export", "GET", exportConfigsHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/import", "POST", importConfigsHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/tokens", "GET", listTokensHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/tokens", "POST", createTokenHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/tokens/{name}", "DELETE", revokeTokenHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/tokens/{name}/expire", "POST", expireTokenHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/schemas", "GET", listSchemasHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/schemas/{name}", "GET", getSchemaHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/schemas/{name}", "PUT", setSchemaHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/schemas/{name}", "DELETE", deleteSchemaHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/validate/{domain:.*}", "POST", validateConfigHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/validate/{domain:.*}", "GET", validateResolvedConfigHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/audit", "GET", listAuditEntriesHandler})
+	routes.PushBack(&ApiRoute{"/api/v1/webhooks/deliveries", "GET", listWebhookDeliveriesHandler})
 
 	for e := routes.Front(); e != nil; e = e.Next() {
 		route := e.Value.(*ApiRoute)
@@ -140,10 +196,10 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	contextLog.Info("Received request")
 
 	// Send CORS and other basic headers
-	w.Header().Set("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization, If-Match, If-None-Match, If-Modified-Since")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, PATCH, POST, DELETE, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Cache-Control", "public,max-age=3600,s-maxage=3600")
 	w.Header().Set("Server", "react-sdk-config-server")
 
 	// Process response
@@ -152,6 +208,22 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		res = &EmptyResponse{}
 	}
 
+	if _, ok := res.(*api.NotModifiedResponse); ok {
+		contextLog.Info("Replying with Not Modified")
+		w.WriteHeader(http.StatusNotModified)
+		requestComplete(http.StatusNotModified, contextLog)
+		return
+	}
+
+	if raw, ok := res.(*api.RawResponse); ok {
+		contextLog.Info("Replying with " + raw.ContentType + " (" + strconv.Itoa(len(raw.Body)) + " bytes)")
+		w.Header().Set("Content-Type", raw.ContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(raw.Body)
+		requestComplete(http.StatusOK, contextLog)
+		return
+	}
+
 	if unk, ok := res.(*api.UnknownContentTypeResponse); ok {
 		m, isMap := unk.Value.(map[string]interface{})
 		c, isConf := unk.Value.(models.ReactConfig)
@@ -200,6 +272,18 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case "M_METHOD_NOT_ALLOWED":
 			statusCode = http.StatusMethodNotAllowed
 			break
+		case "M_FORBIDDEN":
+			statusCode = http.StatusForbidden
+			break
+		case "M_CONFLICT":
+			statusCode = http.StatusConflict
+			break
+		case "M_PRECONDITION_FAILED":
+			statusCode = http.StatusPreconditionFailed
+			break
+		case "M_NOT_IMPLEMENTED":
+			statusCode = http.StatusNotImplemented
+			break
 		default: // M_UNKNOWN
 			statusCode = http.StatusInternalServerError
 			break
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/homeserver-today/react-sdk-config-server/api/rest"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+// adminBackend is where the admin tool's commands are carried out: either the REST API of a running
+// server, or the server's storage directly.
+type adminBackend interface {
+	// GetConfig gets the domain's own config, without any templates applied
+	GetConfig(domain string) (models.ReactConfig, error)
+	// ResolveConfig gets the config that would be served for the domain
+	ResolveConfig(domain string) (models.ReactConfig, error)
+	// ExplainConfig gets the config that would be served for the domain, and where each value came from
+	ExplainConfig(domain string) (*rest.ExplainResponse, error)
+	SetConfig(domain string, config models.ReactConfig) (models.ReactConfig, error)
+	PatchConfig(domain string, contentType string, patch []byte) (models.ReactConfig, error)
+	DeleteConfig(domain string) (error)
+	ListConfigs(filter *models.ConfigFilter) ([]*models.ConfigSummary, error)
+	Export() (*models.ConfigExport, error)
+	Import(configs []*models.ExportedConfig, mode string, dryRun bool) (*models.ImportResult, error)
+	// Close waits for anything the commands started in the background, such as webhook deliveries
+	Close()
+}
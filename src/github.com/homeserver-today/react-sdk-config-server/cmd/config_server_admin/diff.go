@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+)
+
+func isNotFound(err error) (bool) {
+	if err == storage.ErrConfigNotFound {
+		return true
+	}
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.InternalCode == "M_NOT_FOUND"
+}
+
+// diffConfigs describes each change between the configs, one line per changed key. Nested objects
+// are compared key by key.
+func diffConfigs(current models.ReactConfig, updated models.ReactConfig) ([]string, error) {
+	currentMap, err := toJsonMap(current)
+	if err != nil {
+		return nil, err
+	}
+	updatedMap, err := toJsonMap(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]string, 0)
+	diffMaps("", currentMap, updatedMap, &changes)
+	return changes, nil
+}
+
+func diffMaps(prefix string, current map[string]interface{}, updated map[string]interface{}, changes *[]string) {
+	keys := make([]string, 0)
+	for k := range current {
+		keys = append(keys, k)
+	}
+	for k := range updated {
+		if _, found := current[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		path := prefix + k
+		currentVal, inCurrent := current[k]
+		updatedVal, inUpdated := updated[k]
+
+		if !inUpdated {
+			*changes = append(*changes, "- "+path+": "+toJsonString(currentVal))
+		} else if !inCurrent {
+			*changes = append(*changes, "+ "+path+": "+toJsonString(updatedVal))
+		} else if !reflect.DeepEqual(currentVal, updatedVal) {
+			currentObj, currentIsObj := currentVal.(map[string]interface{})
+			updatedObj, updatedIsObj := updatedVal.(map[string]interface{})
+			if currentIsObj && updatedIsObj {
+				diffMaps(path+".", currentObj, updatedObj, changes)
+			} else {
+				*changes = append(*changes, "~ "+path+": "+toJsonString(currentVal)+" -> "+toJsonString(updatedVal))
+			}
+		}
+	}
+}
+
+// toJsonMap round trips the config through JSON so that values can be compared
+func toJsonMap(config models.ReactConfig) (map[string]interface{}, error) {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
+
+func toJsonString(value interface{}) (string) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "?"
+	}
+	return string(b)
+}
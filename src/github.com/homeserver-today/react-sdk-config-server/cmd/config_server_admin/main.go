@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/sirupsen/logrus"
+)
+
+const usage = `Usage: config_server_admin [options] <command> [arguments]
+
+Commands:
+  get <domain>                  Show the domain's own config, without templates applied
+  resolve <domain>              Show the config that is served for the domain (-explain to show where values came from)
+  set <domain> <file>           Replace the domain's config with the JSON in the file
+  patch <domain> <file>         Apply a JSON Merge Patch (or JSON Patch with -type json) to the domain's config
+  delete <domain>               Delete the domain's config
+  list                          List the domains and templates with configs (-type glob|named|domain, -prefix, -search, -key)
+  export                        Write every config to stdout (as NDJSON with -format ndjson)
+  import <file>                 Import configs from an export (-mode merge|replace, -dry-run)
+  diff <domain> <file>          Show how the JSON in the file differs from the domain's config
+
+Files may be given as - to read from stdin.
+
+Options:
+`
+
+func main() {
+	serverUrl := flag.String("server", "http://localhost:8000", "The URL of the config server")
+	token := flag.String("token", os.Getenv("CONFIG_SERVER_TOKEN"), "The API token to use (defaults to $CONFIG_SERVER_TOKEN)")
+	configPath := flag.String("config", "", "The path to the server's configuration. When set, storage is used directly instead of the API")
+	migrationsPath := flag.String("migrations", "./migrations", "The absolute path the migrations folder, when using -config")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var backend adminBackend
+	if *configPath != "" {
+		// Only problems should be shown, as stdout is for the command's output
+		logrus.SetLevel(logrus.WarnLevel)
+		logrus.SetOutput(os.Stderr)
+
+		config.Path = *configPath
+		config.Runtime.MigrationsPath = *migrationsPath
+
+		storeBackend, err := newStoreBackend()
+		if err != nil {
+			exitWithError(err)
+		}
+		backend = storeBackend
+	} else {
+		if *token == "" {
+			exitWithError(fmt.Errorf("an API token is required (use -token or $CONFIG_SERVER_TOKEN)"))
+		}
+		backend = newRestBackend(*serverUrl, *token)
+	}
+
+	command := args[0]
+	err := runCommand(backend, command, args[1:])
+	backend.Close()
+	if err == errUsage {
+		fmt.Fprintln(os.Stderr, "Invalid arguments for "+command)
+		flag.Usage()
+		os.Exit(2)
+	} else if err != nil {
+		exitWithError(err)
+	}
+}
+
+var errUsage = fmt.Errorf("invalid arguments")
+
+func runCommand(backend adminBackend, command string, args []string) (error) {
+	// Each command only accepts the options which apply to it
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	patchType := "merge"
+	exportFormat := "json"
+	importMode := models.ImportModeMerge
+	dryRun := false
+	exitCode := false
+	explain := false
+	filter := &models.ConfigFilter{}
+	switch command {
+	case "patch":
+		flags.StringVar(&patchType, "type", patchType, "The type of patch: merge or json")
+		break
+	case "export", "import":
+		flags.StringVar(&exportFormat, "format", exportFormat, "The format of the export: json or ndjson")
+		if command == "import" {
+			flags.StringVar(&importMode, "mode", importMode, "How to import: merge or replace")
+			flags.BoolVar(&dryRun, "dry-run", dryRun, "Only report what the import would change")
+		}
+		break
+	case "resolve":
+		flags.BoolVar(&explain, "explain", explain, "Show where each value came from, and the templates used")
+		break
+	case "diff":
+		flags.BoolVar(&exitCode, "exit-code", exitCode, "Exit with 1 if there are differences")
+		break
+	case "list":
+		flags.StringVar(&filter.Type, "type", "", "Only list globs (glob), named templates (named) or domains (domain)")
+		flags.StringVar(&filter.Prefix, "prefix", "", "Only list domains starting with this")
+		flags.StringVar(&filter.Search, "search", "", "Only list domains containing this")
+		flags.StringVar(&filter.Key, "key", "", "Only list domains whose config sets this key")
+		break
+	}
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	args = flags.Args()
+
+	switch command {
+	case "get":
+		if len(args) != 1 {
+			return errUsage
+		}
+		conf, err := backend.GetConfig(args[0])
+		if err != nil {
+			return err
+		}
+		return printJson(conf)
+	case "resolve":
+		if len(args) != 1 {
+			return errUsage
+		}
+		if explain {
+			explanation, err := backend.ExplainConfig(args[0])
+			if err != nil {
+				return err
+			}
+			return printJson(explanation)
+		}
+		conf, err := backend.ResolveConfig(args[0])
+		if err != nil {
+			return err
+		}
+		return printJson(conf)
+	case "set":
+		if len(args) != 2 {
+			return errUsage
+		}
+		conf, err := readConfigFile(args[1])
+		if err != nil {
+			return err
+		}
+		newConf, err := backend.SetConfig(args[0], conf)
+		if err != nil {
+			return err
+		}
+		return printJson(newConf)
+	case "patch":
+		if len(args) != 2 {
+			return errUsage
+		}
+		contentType := models.MergePatchContentType
+		if patchType == "json" {
+			contentType = models.JsonPatchContentType
+		} else if patchType != "merge" {
+			return errUsage
+		}
+		patch, err := readFile(args[1])
+		if err != nil {
+			return err
+		}
+		newConf, err := backend.PatchConfig(args[0], contentType, patch)
+		if err != nil {
+			return err
+		}
+		return printJson(newConf)
+	case "delete":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return backend.DeleteConfig(args[0])
+	case "list":
+		if len(args) != 0 {
+			return errUsage
+		}
+		summaries, err := backend.ListConfigs(filter)
+		if err != nil {
+			return err
+		}
+		for _, s := range summaries {
+			fmt.Println(s.Domain)
+		}
+		return nil
+	case "export":
+		if len(args) != 0 {
+			return errUsage
+		}
+		export, err := backend.Export()
+		if err != nil {
+			return err
+		}
+		if exportFormat == "ndjson" {
+			b, err := export.WriteNdjson()
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(b)
+			return err
+		} else if exportFormat != "json" {
+			return errUsage
+		}
+		return printJson(export)
+	case "import":
+		if len(args) != 1 {
+			return errUsage
+		}
+		b, err := readFile(args[0])
+		if err != nil {
+			return err
+		}
+		// Exports are either a single JSON object or one object per line
+		contentType := "application/json"
+		if exportFormat == "ndjson" || strings.HasSuffix(args[0], ".ndjson") {
+			contentType = models.NdjsonContentType
+		}
+		configs, err := models.ParseConfigExport(contentType, b)
+		if err != nil {
+			return err
+		}
+		result, err := backend.Import(configs, importMode, dryRun)
+		if err != nil {
+			return err
+		}
+		return printJson(result)
+	case "diff":
+		if len(args) != 2 {
+			return errUsage
+		}
+		newConf, err := readConfigFile(args[1])
+		if err != nil {
+			return err
+		}
+		currentConf, err := backend.GetConfig(args[0])
+		if isNotFound(err) {
+			currentConf = models.ReactConfig{}
+		} else if err != nil {
+			return err
+		}
+		changes, err := diffConfigs(currentConf, newConf)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		if len(changes) > 0 && exitCode {
+			os.Exit(1)
+		}
+		return nil
+	default:
+		return errUsage
+	}
+}
+
+func readFile(fileName string) ([]byte, error) {
+	if fileName == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(fileName)
+}
+
+func readConfigFile(fileName string) (models.ReactConfig, error) {
+	b, err := readFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := models.ReactConfig{}
+	err = json.Unmarshal(b, &conf)
+	return conf, err
+}
+
+func printJson(value interface{}) (error) {
+	b, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// exitWithError prints the error (using the server's error response, if there was one) and exits
+func exitWithError(err error) {
+	if apiErr, ok := err.(*apiError); ok {
+		b, _ := json.MarshalIndent(apiErr.ErrorResponse, "", "    ")
+		fmt.Fprintln(os.Stderr, string(b))
+	} else {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+	}
+	os.Exit(1)
+}
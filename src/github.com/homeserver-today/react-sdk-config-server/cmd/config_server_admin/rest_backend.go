@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/homeserver-today/react-sdk-config-server/api"
+	"github.com/homeserver-today/react-sdk-config-server/api/rest"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+// apiError is an error response sent by the server
+type apiError struct {
+	*api.ErrorResponse
+	StatusCode int
+}
+
+func (e *apiError) Error() string {
+	return e.InternalCode + ": " + e.Message
+}
+
+type restBackend struct {
+	serverUrl string
+	token     string
+	client    *http.Client
+}
+
+func newRestBackend(serverUrl string, token string) (*restBackend) {
+	return &restBackend{
+		serverUrl: strings.TrimSuffix(serverUrl, "/"),
+		token:     token,
+		client:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// do sends the request, decoding the response into result (if not nil)
+func (b *restBackend) do(method string, path string, contentType string, body io.Reader, result interface{}) (error) {
+	req, err := http.NewRequest(method, b.serverUrl+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		errResponse := &api.ErrorResponse{}
+		if json.Unmarshal(resBody, errResponse) != nil || errResponse.Code == "" {
+			errResponse = &api.ErrorResponse{Code: "M_UNKNOWN", Message: string(resBody), InternalCode: "M_UNKNOWN"}
+		}
+		return &apiError{ErrorResponse: errResponse, StatusCode: res.StatusCode}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resBody, result)
+}
+
+func (b *restBackend) sendJson(method string, path string, contentType string, value interface{}, result interface{}) (error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return b.do(method, path, contentType, bytes.NewReader(body), result)
+}
+
+func configPath(domain string) (string) {
+	return "/api/v1/config/" + url.PathEscape(domain)
+}
+
+func (b *restBackend) GetConfig(domain string) (models.ReactConfig, error) {
+	config := models.ReactConfig{}
+	err := b.do("GET", configPath(domain)+"?stored=true", "", nil, &config)
+	return config, err
+}
+
+func (b *restBackend) ResolveConfig(domain string) (models.ReactConfig, error) {
+	config := models.ReactConfig{}
+	err := b.do("GET", configPath(domain), "", nil, &config)
+	return config, err
+}
+
+func (b *restBackend) ExplainConfig(domain string) (*rest.ExplainResponse, error) {
+	explanation := &rest.ExplainResponse{}
+	err := b.do("GET", "/api/v1/resolve/"+url.PathEscape(domain)+"?explain=true", "", nil, explanation)
+	return explanation, err
+}
+
+func (b *restBackend) SetConfig(domain string, config models.ReactConfig) (models.ReactConfig, error) {
+	newConfig := models.ReactConfig{}
+	err := b.sendJson("PUT", configPath(domain), "application/json", config, &newConfig)
+	return newConfig, err
+}
+
+func (b *restBackend) PatchConfig(domain string, contentType string, patch []byte) (models.ReactConfig, error) {
+	newConfig := models.ReactConfig{}
+	err := b.do("PATCH", configPath(domain), contentType, bytes.NewReader(patch), &newConfig)
+	return newConfig, err
+}
+
+func (b *restBackend) DeleteConfig(domain string) (error) {
+	return b.do("DELETE", configPath(domain), "", nil, nil)
+}
+
+func (b *restBackend) ListConfigs(filter *models.ConfigFilter) ([]*models.ConfigSummary, error) {
+	query := url.Values{}
+	query.Set("type", filter.Type)
+	query.Set("prefix", filter.Prefix)
+	query.Set("search", filter.Search)
+	query.Set("key", filter.Key)
+
+	results := make([]*models.ConfigSummary, 0)
+	for offset := 0; ; {
+		query.Set("offset", strconv.Itoa(offset))
+
+		page := &rest.ListConfigsResponse{}
+		err := b.do("GET", "/api/v1/configs?"+query.Encode(), "", nil, page)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, page.Configs...)
+		if page.NextOffset == nil {
+			return results, nil
+		}
+		offset = *page.NextOffset
+	}
+}
+
+func (b *restBackend) Export() (*models.ConfigExport, error) {
+	export := &models.ConfigExport{}
+	err := b.do("GET", "/api/v1/export", "", nil, export)
+	return export, err
+}
+
+func (b *restBackend) Import(configs []*models.ExportedConfig, mode string, dryRun bool) (*models.ImportResult, error) {
+	query := url.Values{}
+	query.Set("mode", mode)
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+
+	result := &models.ImportResult{}
+	err := b.sendJson("POST", "/api/v1/import?"+query.Encode(), "application/json", &models.ConfigExport{Configs: configs}, result)
+	return result, err
+}
+
+// Close does nothing, as the server makes the changes
+func (b *restBackend) Close() {
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"github.com/homeserver-today/react-sdk-config-server/api/rest"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/storage"
+	"github.com/homeserver-today/react-sdk-config-server/webhooks"
+	"github.com/sirupsen/logrus"
+)
+
+// The name changes made directly to storage are recorded against in the revision history
+const storeActorName = "config_server_admin"
+
+// storeBackend works with the storage described by the server's config file, without needing a
+// running server.
+type storeBackend struct {
+	ctx context.Context
+	log *logrus.Entry
+}
+
+func newStoreBackend() (*storeBackend, error) {
+	err := storage.OpenStore()
+	if err != nil {
+		return nil, err
+	}
+
+	// So that running servers hear about our changes
+	err = storage.OpenCacheBus()
+	if err != nil {
+		return nil, err
+	}
+
+	return &storeBackend{
+		ctx: context.Background(),
+		log: logrus.WithFields(logrus.Fields{"actor": storeActorName}),
+	}, nil
+}
+
+func (b *storeBackend) GetConfig(domain string) (models.ReactConfig, error) {
+	record, err := storage.GetForwardingCache(b.ctx, b.log).GetStoredConfig(domain)
+	if err != nil {
+		return nil, err
+	}
+	return record.Config, nil
+}
+
+func (b *storeBackend) ResolveConfig(domain string) (models.ReactConfig, error) {
+	config, err := storage.GetForwardingCache(b.ctx, b.log).GetConfig(domain)
+	if err != nil {
+		return nil, err
+	}
+	return *config, nil
+}
+
+func (b *storeBackend) ExplainConfig(domain string) (*rest.ExplainResponse, error) {
+	resolution, err := storage.GetForwardingCache(b.ctx, b.log).ExplainConfig(domain)
+	if err != nil {
+		return nil, err
+	}
+	return rest.NewExplainResponse(domain, resolution), nil
+}
+
+func (b *storeBackend) SetConfig(domain string, config models.ReactConfig) (models.ReactConfig, error) {
+	newConfig, err := storage.GetForwardingCache(b.ctx, b.log).WithActor(storeActorName).SetConfig(domain, &config)
+	if err != nil {
+		return nil, err
+	}
+	return *newConfig, nil
+}
+
+func (b *storeBackend) PatchConfig(domain string, contentType string, patch []byte) (models.ReactConfig, error) {
+	configPatch, err := models.NewConfigPatch(contentType, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	newConfig, err := storage.GetForwardingCache(b.ctx, b.log).WithActor(storeActorName).PatchConfig(domain, configPatch)
+	if err != nil {
+		return nil, err
+	}
+	return *newConfig, nil
+}
+
+func (b *storeBackend) DeleteConfig(domain string) (error) {
+	_, err := storage.GetForwardingCache(b.ctx, b.log).WithActor(storeActorName).DeleteConfig(domain)
+	return err
+}
+
+func (b *storeBackend) ListConfigs(filter *models.ConfigFilter) ([]*models.ConfigSummary, error) {
+	return storage.GetForwardingCache(b.ctx, b.log).ListConfigs(filter)
+}
+
+func (b *storeBackend) Export() (*models.ConfigExport, error) {
+	return storage.GetForwardingCache(b.ctx, b.log).ExportConfigs()
+}
+
+func (b *storeBackend) Import(configs []*models.ExportedConfig, mode string, dryRun bool) (*models.ImportResult, error) {
+	return storage.GetForwardingCache(b.ctx, b.log).WithActor(storeActorName).ImportConfigs(configs, mode, dryRun)
+}
+
+// Close waits for the webhooks to be told about the changes, as nothing else will tell them once we exit
+func (b *storeBackend) Close() {
+	webhooks.GetDispatcher().Close()
+}
@@ -13,6 +13,13 @@ var cacheEntries *prometheus.GaugeVec
 var requestDuration *prometheus.SummaryVec
 var cacheHitCount *prometheus.CounterVec
 var cacheMissCount *prometheus.CounterVec
+var cacheInvalidationCount *prometheus.CounterVec
+var cacheEvictionCount *prometheus.CounterVec
+
+// Reasons for removing entries from the cache
+const InvalidationDomain = "domain"     // the domain's own config changed
+const InvalidationTemplate = "template" // a template used by (or now matching) the domain changed
+const InvalidationFlush = "flush"       // the whole cache was cleared
 
 func initMetrics() {
 	logrus.Info("Creating metrics...")
@@ -52,6 +59,18 @@ func initMetrics() {
 		Help: "Number of misses on the cache",
 	}, []string{"name"})
 	prometheus.MustRegister(cacheMissCount)
+
+	cacheInvalidationCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_server_cache_invalidation_count",
+		Help: "Number of entries removed from the cache because a config changed",
+	}, []string{"name", "reason"})
+	prometheus.MustRegister(cacheInvalidationCount)
+
+	cacheEvictionCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_server_cache_eviction_count",
+		Help: "Number of entries evicted from the cache to make room for others",
+	}, []string{"name"})
+	prometheus.MustRegister(cacheEvictionCount)
 }
 
 func IncRequest(method string, handlerName string) {
@@ -106,3 +125,24 @@ func IncCacheMiss(name string) {
 		"name": name,
 	}).Inc()
 }
+
+func IncCacheInvalidation(name string, reason string, count int) {
+	if !config.Get().Metrics.Enabled {
+		return
+	}
+
+	cacheInvalidationCount.With(prometheus.Labels{
+		"name":   name,
+		"reason": reason,
+	}).Add(float64(count))
+}
+
+func IncCacheEviction(name string, count int) {
+	if !config.Get().Metrics.Enabled {
+		return
+	}
+
+	cacheEvictionCount.With(prometheus.Labels{
+		"name": name,
+	}).Add(float64(count))
+}
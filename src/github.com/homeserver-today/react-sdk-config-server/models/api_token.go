@@ -0,0 +1,92 @@
+package models
+
+import "github.com/ryanuber/go-glob"
+
+const PermissionRead = "read"
+const PermissionWrite = "write"
+const PermissionDelete = "delete"
+const PermissionAdmin = "admin" // managing API tokens
+
+var AllPermissions = []string{PermissionRead, PermissionWrite, PermissionDelete, PermissionAdmin}
+
+// ApiToken is the identity behind an API request, and what it is allowed to do
+type ApiToken struct {
+	Name        string   `json:"name"`
+	Domains     []string `json:"domains"`
+	Permissions []string `json:"permissions"`
+}
+
+// StoredApiToken is an API token managed through the API. Only a salted hash of the token is kept.
+type StoredApiToken struct {
+	ApiToken
+	Id        string `json:"-"`
+	Salt      string `json:"-"`
+	Hash      string `json:"-"`
+	CreatedTs int64  `json:"created_ts"`
+	ExpiresTs int64  `json:"expires_ts,omitempty"` // 0 for never
+	Revoked   bool   `json:"revoked"`
+}
+
+func (t *StoredApiToken) IsUsable(nowTs int64) (bool) {
+	return !t.Revoked && (t.ExpiresTs == 0 || t.ExpiresTs > nowTs)
+}
+
+func IsValidPermission(permission string) (bool) {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *ApiToken) HasPermission(permission string) (bool) {
+	for _, p := range t.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess is true if the token has the permission for the domain. Domains may be globs, which
+// the token's domain patterns are matched against like any other domain.
+func (t *ApiToken) CanAccess(permission string, domain string) (bool) {
+	if !t.HasPermission(permission) {
+		return false
+	}
+
+	for _, pattern := range t.Domains {
+		if glob.Glob(pattern, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanUseMatcher is true if the token has the permission for every host the matcher could match.
+// Regular expressions can match anything, so need a token with the permission for every domain.
+func (t *ApiToken) CanUseMatcher(permission string, matcher *HostMatcher) (bool) {
+	if matcher == nil {
+		return true
+	}
+
+	for _, pattern := range matcher.Patterns {
+		allowed := false
+		switch matcher.Type {
+		case MatcherGlob, MatcherExact:
+			allowed = t.CanAccess(permission, pattern)
+			break
+		case MatcherSuffix:
+			allowed = t.CanAccess(permission, pattern) && t.CanAccess(permission, "*."+pattern)
+			break
+		default:
+			allowed = t.CanAccess(permission, "*")
+			break
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
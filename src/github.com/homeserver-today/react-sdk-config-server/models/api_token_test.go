@@ -0,0 +1,86 @@
+package models
+
+import "testing"
+
+func TestApiTokenCanAccess(t *testing.T) {
+	token := &ApiToken{
+		Name:        "test",
+		Domains:     []string{"t2bot.io", "*.t2bot.io"},
+		Permissions: []string{PermissionRead, PermissionWrite},
+	}
+
+	tests := []struct {
+		name       string
+		permission string
+		domain     string
+		expected   bool
+	}{
+		{"exact domain", PermissionRead, "t2bot.io", true},
+		{"subdomain", PermissionWrite, "chat.t2bot.io", true},
+		{"nested subdomain", PermissionRead, "a.chat.t2bot.io", true},
+		{"template within the domains", PermissionWrite, "*.t2bot.io", true},
+		{"other domain", PermissionRead, "example.org", false},
+		{"lookalike domain", PermissionRead, "nott2bot.io", false},
+		{"template wider than the domains", PermissionWrite, "*.io", false},
+		{"every domain", PermissionRead, "*", false},
+		{"missing permission", PermissionDelete, "t2bot.io", false},
+		{"unknown permission", "everything", "t2bot.io", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := token.CanAccess(test.permission, test.domain); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+
+	everything := &ApiToken{Domains: []string{"*"}, Permissions: []string{PermissionRead}}
+	for _, domain := range []string{"t2bot.io", "*.example.org", "*", NamedTemplatePrefix + "base"} {
+		if !everything.CanAccess(PermissionRead, domain) {
+			t.Errorf("expected a token for every domain to access %s", domain)
+		}
+	}
+
+	nothing := &ApiToken{Domains: []string{}, Permissions: []string{PermissionRead}}
+	if nothing.CanAccess(PermissionRead, "t2bot.io") {
+		t.Error("expected a token without domains to access nothing")
+	}
+}
+
+func TestApiTokenCanUseMatcher(t *testing.T) {
+	token := &ApiToken{
+		Name:        "test",
+		Domains:     []string{"t2bot.io", "*.t2bot.io"},
+		Permissions: []string{PermissionWrite},
+	}
+	subdomainsOnly := &ApiToken{Domains: []string{"*.t2bot.io"}, Permissions: []string{PermissionWrite}}
+	everything := &ApiToken{Domains: []string{"*"}, Permissions: []string{PermissionWrite}}
+
+	tests := []struct {
+		name     string
+		token    *ApiToken
+		matcher  *HostMatcher
+		expected bool
+	}{
+		{"no matcher", token, nil, true},
+		{"glob within the domains", token, &HostMatcher{Type: MatcherGlob, Patterns: []string{"*.t2bot.io"}}, true},
+		{"glob wider than the domains", token, &HostMatcher{Type: MatcherGlob, Patterns: []string{"*.io"}}, false},
+		{"exact hosts within the domains", token, &HostMatcher{Type: MatcherExact, Patterns: []string{"t2bot.io", "chat.t2bot.io"}}, true},
+		{"one exact host outside the domains", token, &HostMatcher{Type: MatcherExact, Patterns: []string{"chat.t2bot.io", "example.org"}}, false},
+		{"suffix within the domains", token, &HostMatcher{Type: MatcherSuffix, Patterns: []string{"t2bot.io"}}, true},
+		{"suffix needs the domain itself", subdomainsOnly, &HostMatcher{Type: MatcherSuffix, Patterns: []string{"t2bot.io"}}, false},
+		{"suffix outside the domains", token, &HostMatcher{Type: MatcherSuffix, Patterns: []string{"example.org"}}, false},
+		{"regex needs every domain", token, &HostMatcher{Type: MatcherRegex, Patterns: []string{`chat\.t2bot\.io`}}, false},
+		{"regex with every domain", everything, &HostMatcher{Type: MatcherRegex, Patterns: []string{`.*`}}, true},
+		{"missing permission", &ApiToken{Domains: []string{"*"}, Permissions: []string{PermissionRead}}, &HostMatcher{Type: MatcherGlob, Patterns: []string{"*.t2bot.io"}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.token.CanUseMatcher(PermissionWrite, test.matcher); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
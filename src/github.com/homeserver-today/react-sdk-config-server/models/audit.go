@@ -0,0 +1,147 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Changes recorded in the audit log
+const AuditActionSet = "set"
+const AuditActionPatch = "patch"
+const AuditActionDelete = "delete"
+const AuditActionMetadata = "metadata"
+const AuditActionRollback = "rollback"
+const AuditActionImport = "import"
+
+// Operations in an audit diff, named after their JSON Patch equivalents
+const AuditOpAdd = "add"
+const AuditOpRemove = "remove"
+const AuditOpReplace = "replace"
+
+// AuditEntry records a change to a domain's stored config and metadata
+type AuditEntry struct {
+	Id         int64          `json:"id"`
+	CreatedTs  int64          `json:"created_ts"`
+	Actor      string         `json:"actor"`
+	RemoteAddr string         `json:"remote_addr"`
+	Domain     string         `json:"domain"`
+	Action     string         `json:"action"`
+	Diff       []*AuditChange `json:"diff"`
+}
+
+// AuditChange is a difference between a stored config before and after a change. Paths are JSON
+// Pointers into an object with the config under "config" and the metadata under "metadata", and
+// the empty path is used when the whole config was created or deleted.
+type AuditChange struct {
+	Op     string      `json:"op"`
+	Path   string      `json:"path"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// AuditFilter selects audit entries. Empty fields match everything.
+type AuditFilter struct {
+	Domain   string
+	Actor    string
+	SinceTs  int64 // the entry must have been created at or after this time
+	UntilTs  int64 // the entry must have been created at or before this time
+	BeforeId int64 // the entry must be older than this one, for paging
+	Limit    int
+}
+
+func (f *AuditFilter) Matches(entry *AuditEntry) (bool) {
+	if f.Domain != "" && f.Domain != entry.Domain {
+		return false
+	}
+	if f.Actor != "" && f.Actor != entry.Actor {
+		return false
+	}
+	if f.SinceTs != 0 && entry.CreatedTs < f.SinceTs {
+		return false
+	}
+	if f.UntilTs != 0 && entry.CreatedTs > f.UntilTs {
+		return false
+	}
+	if f.BeforeId != 0 && entry.Id >= f.BeforeId {
+		return false
+	}
+	return true
+}
+
+// DiffStoredConfigs describes the changes between two versions of a stored config. A nil config
+// means that there was no config at the time.
+func DiffStoredConfigs(beforeConfig ReactConfig, beforeMetadata *ConfigMetadata, afterConfig ReactConfig, afterMetadata *ConfigMetadata) ([]*AuditChange, error) {
+	before, err := auditState(beforeConfig, beforeMetadata)
+	if err != nil {
+		return nil, err
+	}
+	after, err := auditState(afterConfig, afterMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]*AuditChange, 0)
+	if before == nil && after == nil {
+		return changes, nil
+	} else if before == nil {
+		return append(changes, &AuditChange{Op: AuditOpAdd, Path: "", After: after}), nil
+	} else if after == nil {
+		return append(changes, &AuditChange{Op: AuditOpRemove, Path: "", Before: before}), nil
+	}
+
+	diffValues("", before, after, &changes)
+	return changes, nil
+}
+
+// auditState round trips the config and metadata through JSON so that they can be compared
+func auditState(config ReactConfig, metadata *ConfigMetadata) (map[string]interface{}, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if metadata == nil {
+		metadata = &ConfigMetadata{}
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"config": config, "metadata": metadata})
+	if err != nil {
+		return nil, err
+	}
+	state := make(map[string]interface{})
+	err = json.Unmarshal(b, &state)
+	return state, err
+}
+
+// diffValues compares objects key by key. Anything else (including arrays) is replaced as a whole.
+func diffValues(path string, before map[string]interface{}, after map[string]interface{}, changes *[]*AuditChange) {
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, found := before[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		keyPath := path + "/" + escapePointer(k)
+		beforeVal, inBefore := before[k]
+		afterVal, inAfter := after[k]
+
+		if !inAfter {
+			*changes = append(*changes, &AuditChange{Op: AuditOpRemove, Path: keyPath, Before: beforeVal})
+		} else if !inBefore {
+			*changes = append(*changes, &AuditChange{Op: AuditOpAdd, Path: keyPath, After: afterVal})
+		} else if !reflect.DeepEqual(beforeVal, afterVal) {
+			beforeObj, beforeIsObj := beforeVal.(map[string]interface{})
+			afterObj, afterIsObj := afterVal.(map[string]interface{})
+			if beforeIsObj && afterIsObj {
+				diffValues(keyPath, beforeObj, afterObj, changes)
+			} else {
+				*changes = append(*changes, &AuditChange{Op: AuditOpReplace, Path: keyPath, Before: beforeVal, After: afterVal})
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+const NdjsonContentType = "application/x-ndjson"
+
+const ImportModeMerge = "merge"     // imported configs replace existing ones, other domains are untouched
+const ImportModeReplace = "replace" // domains which are not imported are deleted
+
+var ErrDuplicateDomain = errors.New("domain is imported more than once")
+
+type ExportedConfig struct {
+	Domain   string          `json:"domain"`
+	Config   ReactConfig     `json:"config"`
+	Metadata *ConfigMetadata `json:"metadata,omitempty"`
+}
+
+// ConfigExport is every stored config, including templates
+type ConfigExport struct {
+	ExportedTs int64             `json:"exported_ts"`
+	Configs    []*ExportedConfig `json:"configs"`
+}
+
+type ImportResult struct {
+	Mode      string   `json:"mode"`
+	DryRun    bool     `json:"dry_run"`
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Deleted   []string `json:"deleted"`
+	Unchanged int      `json:"unchanged"`
+}
+
+func (r *ImportResult) HasChanges() (bool) {
+	return len(r.Created) > 0 || len(r.Updated) > 0 || len(r.Deleted) > 0
+}
+
+// WriteNdjson writes each config as a single line of JSON
+func (e *ConfigExport) WriteNdjson() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, c := range e.Configs {
+		line, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseConfigExport reads either a JSON document or NDJSON lines, depending on the content type
+func ParseConfigExport(contentType string, body []byte) ([]*ExportedConfig, error) {
+	configs := make([]*ExportedConfig, 0)
+
+	if contentType == NdjsonContentType {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+		lineNum := 0
+		for scanner.Scan() {
+			lineNum++
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+
+			c := &ExportedConfig{}
+			err := json.Unmarshal(line, c)
+			if err != nil {
+				return nil, errors.New("line " + strconv.Itoa(lineNum) + ": " + err.Error())
+			}
+			configs = append(configs, c)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		export := &ConfigExport{}
+		err := json.Unmarshal(body, export)
+		if err != nil {
+			return nil, err
+		}
+		if export.Configs != nil {
+			configs = export.Configs
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range configs {
+		if c == nil || c.Domain == "" {
+			return nil, errors.New("every config must have a domain")
+		}
+		if seen[c.Domain] {
+			return nil, ErrDuplicateDomain
+		}
+		seen[c.Domain] = true
+		if c.Config == nil {
+			c.Config = ReactConfig{}
+		}
+	}
+
+	return configs, nil
+}
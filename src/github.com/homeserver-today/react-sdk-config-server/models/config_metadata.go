@@ -0,0 +1,181 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+// Config keys which were used for metadata before it was stored separately. They are still accepted
+// when setting a config, and are moved into the metadata.
+const LegacyWeightKey = "hstoday.weight"
+const LegacyDescriptionKey = "hstoday.description"
+const LegacyOwnerKey = "hstoday.owner"
+const LegacyEnabledKey = "hstoday.enabled"
+
+// Keys which hold the rest of the metadata in configs stored by backends which keep metadata in the config
+const MergeKey = "hstoday.merge"
+const MatcherKey = "hstoday.matcher"
+const ExtendsKey = "hstoday.extends"
+
+var metadataKeys = []string{LegacyWeightKey, LegacyDescriptionKey, LegacyOwnerKey, LegacyEnabledKey, MergeKey, MatcherKey, ExtendsKey}
+
+// ConfigMetadata is information about a config which is used by the server, but not served to clients
+type ConfigMetadata struct {
+	// Templates are applied in ascending order of weight
+	Weight      int    `json:"weight"`
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+
+	// Disabled configs are ignored when calculating the config for a domain. Nil means enabled.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// How the config's values are merged with those from earlier templates, by key. Nested keys are
+	// separated by dots (eg: "roomDirectory.servers"). Keys which aren't listed use MergeOverride.
+	Merge map[string]string `json:"merge,omitempty"`
+
+	// Which hosts a template is used for. Templates without a matcher use their name as a glob.
+	Matcher *HostMatcher `json:"matcher,omitempty"`
+
+	// Named templates which the config is based on. Later templates take priority over earlier ones,
+	// and the config itself takes priority over all of them.
+	Extends []string `json:"extends,omitempty"`
+}
+
+func (m *ConfigMetadata) IsEnabled() (bool) {
+	return m.Enabled == nil || *m.Enabled
+}
+
+// Validate checks that the metadata can be used, returning a *ValidationError if not
+func (m *ConfigMetadata) Validate() (error) {
+	for key, strategy := range m.Merge {
+		if key == "" {
+			return &ValidationError{"Merge strategies must be given for a key"}
+		}
+		if !IsValidMergeStrategy(strategy) {
+			return &ValidationError{"Unknown merge strategy for " + key + ": " + strategy}
+		}
+	}
+	if m.Matcher != nil {
+		return m.Matcher.Validate()
+	}
+	return nil
+}
+
+// ExtractMetadata moves any metadata keys out of the config and into the metadata. The config is not
+// modified - a copy without the keys is returned, along with whether any keys were found.
+func ExtractMetadata(config ReactConfig, metadata *ConfigMetadata) (ReactConfig, bool, error) {
+	found := false
+	for _, k := range metadataKeys {
+		if _, ok := config[k]; ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return config, false, nil
+	}
+
+	cleaned := ReactConfig{}
+	for k, v := range config {
+		cleaned[k] = v
+	}
+
+	for _, k := range metadataKeys {
+		v, ok := cleaned[k]
+		if !ok {
+			continue
+		}
+		delete(cleaned, k)
+		if v == nil {
+			continue
+		}
+
+		var valid bool
+		switch k {
+		case LegacyWeightKey:
+			var weight float64
+			weight, valid = v.(float64)
+			metadata.Weight = int(weight)
+			break
+		case LegacyDescriptionKey:
+			metadata.Description, valid = v.(string)
+			break
+		case LegacyOwnerKey:
+			metadata.Owner, valid = v.(string)
+			break
+		case LegacyEnabledKey:
+			var enabled bool
+			enabled, valid = v.(bool)
+			metadata.Enabled = &enabled
+			break
+		case MergeKey:
+			var strategies map[string]interface{}
+			strategies, valid = v.(map[string]interface{})
+			metadata.Merge = make(map[string]string)
+			for key, strategy := range strategies {
+				metadata.Merge[key], valid = strategy.(string)
+				if !valid {
+					break
+				}
+			}
+			break
+		case MatcherKey:
+			metadata.Matcher = &HostMatcher{}
+			b, err := json.Marshal(v)
+			valid = err == nil && json.Unmarshal(b, metadata.Matcher) == nil
+			break
+		case ExtendsKey:
+			var parents []interface{}
+			parents, valid = v.([]interface{})
+			metadata.Extends = make([]string, len(parents))
+			for i, parent := range parents {
+				metadata.Extends[i], valid = parent.(string)
+				if !valid {
+					break
+				}
+			}
+			break
+		}
+		if !valid {
+			return nil, true, &ValidationError{"Invalid value for " + k}
+		}
+	}
+
+	return cleaned, true, nil
+}
+
+// WithLegacyKeys returns a copy of the config with the metadata added as legacy keys, for backends
+// which cannot store metadata separately.
+func (m *ConfigMetadata) WithLegacyKeys(config ReactConfig) (ReactConfig) {
+	combined := ReactConfig{}
+	for k, v := range config {
+		combined[k] = v
+	}
+
+	if m.Weight != 0 {
+		combined[LegacyWeightKey] = m.Weight
+	}
+	if m.Description != "" {
+		combined[LegacyDescriptionKey] = m.Description
+	}
+	if m.Owner != "" {
+		combined[LegacyOwnerKey] = m.Owner
+	}
+	if m.Enabled != nil {
+		combined[LegacyEnabledKey] = *m.Enabled
+	}
+	if len(m.Merge) > 0 {
+		strategies := make(map[string]interface{})
+		for key, strategy := range m.Merge {
+			strategies[key] = strategy
+		}
+		combined[MergeKey] = strategies
+	}
+	if m.Matcher != nil {
+		combined[MatcherKey] = m.Matcher
+	}
+	if len(m.Extends) > 0 {
+		combined[ExtendsKey] = m.Extends
+	}
+
+	return combined
+}
@@ -0,0 +1,16 @@
+package models
+
+const RevisionActionSet = "set"
+const RevisionActionDelete = "delete"
+
+type ConfigRevision struct {
+	Domain    string      `json:"domain"`
+	Revision  int         `json:"revision"`
+	CreatedTs int64       `json:"created_ts"`
+	Actor     string      `json:"actor"`
+	Action    string      `json:"action"`
+	Config    ReactConfig `json:"config,omitempty"`
+
+	// Revisions recorded before metadata was stored separately do not have metadata
+	Metadata *ConfigMetadata `json:"metadata,omitempty"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// StoredSchema is a JSON Schema which configs can be validated against
+type StoredSchema struct {
+	Name      string          `json:"name"`
+	Schema    json.RawMessage `json:"schema"`
+	UpdatedTs int64           `json:"updated_ts,omitempty"`
+}
+
+// SchemaError is a problem with the value at a JSON Pointer (RFC 6901) in a config
+type SchemaError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// SchemaValidationError is returned when a config does not match the schema it is validated against
+type SchemaValidationError struct {
+	Domain string
+	Schema string
+	Errors []*SchemaError
+}
+
+func (e *SchemaValidationError) Error() (string) {
+	message := "Config for " + e.Domain + " does not match schema " + e.Schema
+	if len(e.Errors) == 1 {
+		return message + ": " + e.Errors[0].Path + ": " + e.Errors[0].Message
+	}
+	return message + " (" + strconv.Itoa(len(e.Errors)) + " problems)"
+}
+
+// ValidationResult describes whether a config is valid, for when that is the question being asked
+type ValidationResult struct {
+	Valid  bool           `json:"valid"`
+	Schema string         `json:"schema,omitempty"`
+	Errors []*SchemaError `json:"errors"`
+}
+
+// ConfigSchema is a compiled JSON Schema
+type ConfigSchema struct {
+	name   string
+	schema *gojsonschema.Schema
+}
+
+// Used to split the paths given by the validator, as it would otherwise be ambiguous with keys
+const contextDelimiter = "\x00"
+
+// CompileSchema prepares the schema for use, returning a *ValidationError if it is not a valid schema
+func CompileSchema(name string, schema []byte) (*ConfigSchema, error) {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		return nil, &ValidationError{"Invalid schema " + name + ": " + err.Error()}
+	}
+	return &ConfigSchema{name: name, schema: compiled}, nil
+}
+
+func (s *ConfigSchema) Name() (string) {
+	return s.name
+}
+
+// Validate checks the domain's config against the schema, returning a *SchemaValidationError
+// describing every problem found. The $unset key is not part of the served config, so it is ignored.
+func (s *ConfigSchema) Validate(domain string, config ReactConfig) (error) {
+	if _, found := config[UnsetKey]; found {
+		withoutUnset := ReactConfig{}
+		for k, v := range config {
+			if k != UnsetKey {
+				withoutUnset[k] = v
+			}
+		}
+		config = withoutUnset
+	}
+
+	result, err := s.schema.Validate(gojsonschema.NewGoLoader(config))
+	if err != nil {
+		return err
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	validationErr := &SchemaValidationError{Domain: domain, Schema: s.name, Errors: make([]*SchemaError, 0)}
+	for _, resultErr := range result.Errors() {
+		path := schemaErrorPath(resultErr.Context())
+
+		// Point at the unexpected key itself, as that is most likely where the typo is
+		if resultErr.Type() == "additional_property_not_allowed" {
+			if property, ok := resultErr.Details()["property"].(string); ok {
+				path += "/" + escapePointer(property)
+			}
+		}
+
+		validationErr.Errors = append(validationErr.Errors, &SchemaError{Path: path, Message: resultErr.Description()})
+	}
+	return validationErr
+}
+
+// schemaErrorPath converts the validator's description of where a problem is to a JSON Pointer
+func schemaErrorPath(context *gojsonschema.JsonContext) (string) {
+	if context == nil {
+		return ""
+	}
+
+	// The first part is always the validator's name for the root
+	parts := strings.Split(context.String(contextDelimiter), contextDelimiter)
+	path := ""
+	for _, part := range parts[1:] {
+		path += "/" + escapePointer(part)
+	}
+	return path
+}
+
+func escapePointer(key string) (string) {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
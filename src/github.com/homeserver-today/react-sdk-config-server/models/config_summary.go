@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+)
+
+const ConfigTypeGlob = "glob"
+const ConfigTypeDomain = "domain"
+const ConfigTypeNamed = "named"
+
+// ConfigSummary describes a stored config without including the config itself
+type ConfigSummary struct {
+	Domain          string          `json:"domain"`
+	IsGlob          bool            `json:"is_glob"`
+	IsNamedTemplate bool            `json:"is_named_template"`
+	UpdatedTs       int64           `json:"updated_ts"`
+	Metadata        *ConfigMetadata `json:"metadata"`
+}
+
+// ConfigFilter selects stored configs. Empty fields match everything.
+type ConfigFilter struct {
+	Type   string // ConfigTypeGlob, ConfigTypeNamed or ConfigTypeDomain
+	Prefix string // the domain must start with this
+	Search string // the domain must contain this, ignoring case
+	Key    string // the config must set this key. Nested keys are separated by dots (eg: "features.feature_groups")
+}
+
+func (f *ConfigFilter) Matches(domain string, config ReactConfig) (bool) {
+	configType := ConfigTypeDomain
+	if IsNamedTemplate(domain) {
+		configType = ConfigTypeNamed
+	} else if strings.Contains(domain, "*") {
+		configType = ConfigTypeGlob
+	}
+	if f.Type != "" && f.Type != configType {
+		return false
+	}
+	if f.Prefix != "" && !strings.HasPrefix(domain, f.Prefix) {
+		return false
+	}
+	if f.Search != "" && !strings.Contains(strings.ToLower(domain), strings.ToLower(f.Search)) {
+		return false
+	}
+	if f.Key != "" && !config.HasKey(f.Key) {
+		return false
+	}
+	return true
+}
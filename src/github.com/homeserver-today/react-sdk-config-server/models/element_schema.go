@@ -0,0 +1,224 @@
+package models
+
+// ElementSchemaName is the name of the bundled schema for Element (Riot) config.json files
+const ElementSchemaName = "element"
+
+// ElementSchema describes the options understood by Element (and Riot before it). Configs are often
+// only part of what is served, so nothing is required, but unknown options are rejected to catch
+// typos.
+const ElementSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "Element config.json",
+	"type": "object",
+	"definitions": {
+		"url": {"type": "string"},
+		"urls": {"type": "array", "items": {"$ref": "#/definitions/url"}},
+		"serverConfig": {
+			"type": "object",
+			"properties": {
+				"m.homeserver": {
+					"type": "object",
+					"properties": {
+						"base_url": {"$ref": "#/definitions/url"},
+						"server_name": {"type": "string"}
+					}
+				},
+				"m.identity_server": {
+					"type": "object",
+					"properties": {
+						"base_url": {"$ref": "#/definitions/url"}
+					}
+				}
+			}
+		},
+		"roomDirectory": {
+			"type": "object",
+			"properties": {
+				"servers": {"type": "array", "items": {"type": "string"}}
+			}
+		}
+	},
+	"properties": {
+		"default_server_config": {"$ref": "#/definitions/serverConfig"},
+		"default_server_name": {"type": "string"},
+		"default_hs_url": {"$ref": "#/definitions/url"},
+		"default_is_url": {"$ref": "#/definitions/url"},
+		"validated_server_config": {"type": "object"},
+		"disable_custom_urls": {"type": "boolean"},
+		"disable_guests": {"type": "boolean"},
+		"disable_login_language_selector": {"type": "boolean"},
+		"disable_3pid_login": {"type": "boolean"},
+		"brand": {"type": "string"},
+		"branding": {
+			"type": "object",
+			"properties": {
+				"welcome_background_url": {"oneOf": [{"$ref": "#/definitions/url"}, {"$ref": "#/definitions/urls"}]},
+				"auth_header_logo_url": {"$ref": "#/definitions/url"},
+				"auth_footer_links": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": {
+							"text": {"type": "string"},
+							"url": {"$ref": "#/definitions/url"}
+						}
+					}
+				}
+			}
+		},
+		"embedded_pages": {
+			"type": "object",
+			"properties": {
+				"welcome_url": {"$ref": "#/definitions/url"},
+				"home_url": {"$ref": "#/definitions/url"},
+				"login_for_welcome": {"type": "boolean"}
+			}
+		},
+		"integrations_ui_url": {"$ref": "#/definitions/url"},
+		"integrations_rest_url": {"$ref": "#/definitions/url"},
+		"integrations_widgets_urls": {"$ref": "#/definitions/urls"},
+		"integrations_jitsi_widget_url": {"$ref": "#/definitions/url"},
+		"bug_report_endpoint_url": {"$ref": "#/definitions/url"},
+		"uisi_autorageshake_app": {"type": "string"},
+		"sentry": {
+			"type": "object",
+			"properties": {
+				"dsn": {"type": "string"},
+				"environment": {"type": "string"}
+			}
+		},
+		"showLabsSettings": {"type": "boolean"},
+		"show_labs_settings": {"type": "boolean"},
+		"features": {
+			"type": "object",
+			"additionalProperties": {"type": ["boolean", "string"]}
+		},
+		"default_federate": {"type": "boolean"},
+		"default_theme": {"type": "string"},
+		"default_country_code": {"type": "string"},
+		"defaultCountryCode": {"type": "string"},
+		"roomDirectory": {"$ref": "#/definitions/roomDirectory"},
+		"room_directory": {"$ref": "#/definitions/roomDirectory"},
+		"piwik": {
+			"oneOf": [
+				{"type": "boolean", "enum": [false]},
+				{
+					"type": "object",
+					"properties": {
+						"url": {"$ref": "#/definitions/url"},
+						"siteId": {"type": ["integer", "string"]},
+						"whitelistedHSUrls": {"$ref": "#/definitions/urls"},
+						"whitelistedISUrls": {"$ref": "#/definitions/urls"},
+						"policyUrl": {"$ref": "#/definitions/url"}
+					}
+				}
+			]
+		},
+		"posthog": {
+			"type": "object",
+			"properties": {
+				"project_api_key": {"type": "string"},
+				"api_host": {"$ref": "#/definitions/url"}
+			}
+		},
+		"analytics_owner": {"type": "string"},
+		"privacy_policy_url": {"$ref": "#/definitions/url"},
+		"settingDefaults": {"type": "object"},
+		"setting_defaults": {"type": "object"},
+		"enable_presence_by_hs_url": {
+			"type": "object",
+			"additionalProperties": {"type": "boolean"}
+		},
+		"jitsi": {
+			"type": "object",
+			"properties": {
+				"preferredDomain": {"type": "string"},
+				"preferred_domain": {"type": "string"}
+			}
+		},
+		"jitsi_widget": {
+			"type": "object",
+			"properties": {
+				"skip_built_in_welcome_screen": {"type": "boolean"}
+			}
+		},
+		"voip": {
+			"type": "object",
+			"properties": {
+				"obey_asserted_identity": {"type": "boolean"}
+			}
+		},
+		"element_call": {
+			"type": "object",
+			"properties": {
+				"url": {"$ref": "#/definitions/url"},
+				"use_exclusively": {"type": "boolean"},
+				"participant_limit": {"type": "integer"},
+				"brand": {"type": "string"}
+			}
+		},
+		"logout_redirect_url": {"$ref": "#/definitions/url"},
+		"sso_redirect_options": {
+			"type": "object",
+			"properties": {
+				"immediate": {"type": "boolean"},
+				"on_welcome_page": {"type": "boolean"}
+			}
+		},
+		"custom_translations_url": {"$ref": "#/definitions/url"},
+		"report_event": {
+			"type": "object",
+			"properties": {
+				"admin_message_md": {"type": "string"}
+			}
+		},
+		"welcomeUserId": {"type": "string"},
+		"welcome_user_id": {"type": "string"},
+		"permalinkPrefix": {"$ref": "#/definitions/url"},
+		"permalink_prefix": {"$ref": "#/definitions/url"},
+		"desktop_builds": {
+			"type": "object",
+			"properties": {
+				"available": {"type": "boolean"},
+				"logo": {"$ref": "#/definitions/url"},
+				"url": {"$ref": "#/definitions/url"}
+			}
+		},
+		"mobile_builds": {
+			"type": "object",
+			"properties": {
+				"ios": {"type": ["string", "null"]},
+				"android": {"type": ["string", "null"]},
+				"fdroid": {"type": ["string", "null"]}
+			}
+		},
+		"mobile_guide_toast": {"type": "boolean"},
+		"mobile_guide_app_variant": {"type": "string"},
+		"audio_stream_url": {"$ref": "#/definitions/url"},
+		"help_url": {"$ref": "#/definitions/url"},
+		"help_encryption_url": {"$ref": "#/definitions/url"},
+		"force_verification": {"type": "boolean"},
+		"map_style_url": {"$ref": "#/definitions/url"},
+		"terms_and_conditions_links": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"text": {"type": "string"},
+					"url": {"$ref": "#/definitions/url"}
+				}
+			}
+		},
+		"default_device_display_name": {"type": "string"},
+		"oidc_static_clients": {"type": "object"},
+		"user_notice": {"type": "object"},
+		"spaces_learn_more_url": {"$ref": "#/definitions/url"},
+		"host_signup": {"type": "object"},
+		"hosting_signup_link": {"$ref": "#/definitions/url"},
+		"cross_origin_renderer_url": {"$ref": "#/definitions/url"},
+		"update_base_url": {"$ref": "#/definitions/url"},
+		"enableLabs": {"type": "boolean"},
+		"show_labs_settings_for_hs": {"type": "object"}
+	},
+	"additionalProperties": false
+}`
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+)
+
+// Configs stored under names starting with this are named templates. They are never used for hosts
+// directly, only through other configs which extend them.
+const NamedTemplatePrefix = "@"
+
+func IsNamedTemplate(name string) (bool) {
+	return strings.HasPrefix(name, NamedTemplatePrefix)
+}
+
+// IsTemplate determines if the name is a glob or a named template, rather than a domain
+func IsTemplate(name string) (bool) {
+	return strings.Contains(name, "*") || IsNamedTemplate(name)
+}
+
+// ExtendsLookup gets the named templates which another config extends, and whether it exists
+type ExtendsLookup func(name string) ([]string, bool)
+
+// CheckExtends makes sure that the config can extend the named templates, returning a
+// *ValidationError if the templates don't exist or if extending them would create a cycle.
+func CheckExtends(name string, extends []string, lookup ExtendsLookup) (error) {
+	for _, parent := range extends {
+		if !IsNamedTemplate(parent) || len(parent) == len(NamedTemplatePrefix) {
+			return &ValidationError{"Only named templates can be extended: " + parent}
+		}
+		if _, found := lookup(parent); !found {
+			return &ValidationError{"Named template not found: " + parent}
+		}
+	}
+
+	// Look for a way back to this config through what its parents extend
+	visited := make(map[string]bool)
+	var reaches func(from string) (bool)
+	reaches = func(from string) (bool) {
+		if from == name {
+			return true
+		}
+		if visited[from] {
+			return false
+		}
+		visited[from] = true
+
+		grandparents, _ := lookup(from)
+		for _, p := range grandparents {
+			if reaches(p) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, parent := range extends {
+		if reaches(parent) {
+			return &ValidationError{"Extending " + parent + " would create a cycle"}
+		}
+	}
+
+	return nil
+}
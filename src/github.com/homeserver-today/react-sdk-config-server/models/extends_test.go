@@ -0,0 +1,70 @@
+package models
+
+import "testing"
+
+func TestCheckExtends(t *testing.T) {
+	// What each stored config extends
+	stored := map[string][]string{
+		"@base":      {},
+		"@branding":  {"@base"},
+		"@chat":      {"@branding"},
+		"@other":     {},
+		"*.t2bot.io": {"@chat"},
+		"t2bot.io":   {"@base"},
+	}
+	lookup := func(name string) ([]string, bool) {
+		extends, found := stored[name]
+		return extends, found
+	}
+
+	tests := []struct {
+		name    string
+		config  string
+		extends []string
+		valid   bool
+	}{
+		{"nothing", "example.org", nil, true},
+		{"one template", "example.org", []string{"@base"}, true},
+		{"several templates", "example.org", []string{"@chat", "@other"}, true},
+		{"same ancestor twice", "@new", []string{"@chat", "@branding"}, true},
+		{"unchanged chain", "@chat", []string{"@branding"}, true},
+		{"missing template", "example.org", []string{"@missing"}, false},
+		{"domain", "example.org", []string{"t2bot.io"}, false},
+		{"glob template", "example.org", []string{"*.t2bot.io"}, false},
+		{"empty name", "example.org", []string{NamedTemplatePrefix}, false},
+		{"itself", "@base", []string{"@base"}, false},
+		{"direct cycle", "@base", []string{"@branding"}, false},
+		{"indirect cycle", "@base", []string{"@chat"}, false},
+		{"cycle through one of several", "@branding", []string{"@other", "@chat"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckExtends(test.config, test.extends, lookup)
+			if test.valid && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if !test.valid {
+				if _, ok := err.(*ValidationError); !ok {
+					t.Errorf("expected a *ValidationError, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckExtendsStoredCycle(t *testing.T) {
+	// Cycles which already exist (such as from editing files on disk) shouldn't stop other configs
+	// from extending the templates involved.
+	stored := map[string][]string{
+		"@a": {"@b"},
+		"@b": {"@a"},
+	}
+	lookup := func(name string) ([]string, bool) {
+		extends, found := stored[name]
+		return extends, found
+	}
+
+	if err := CheckExtends("example.org", []string{"@a"}, lookup); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
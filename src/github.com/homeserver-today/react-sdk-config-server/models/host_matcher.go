@@ -0,0 +1,130 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+)
+
+// Types of host matcher
+const MatcherGlob = "glob"     // * matches any text
+const MatcherRegex = "regex"   // a regular expression which must match the whole host
+const MatcherExact = "exact"   // the host must be one of the patterns
+const MatcherSuffix = "suffix" // the host must be the pattern or a subdomain of it
+
+var AllMatcherTypes = []string{MatcherGlob, MatcherRegex, MatcherExact, MatcherSuffix}
+
+// HostMatcher decides which hosts a template is used for. A host matches if it matches any of the
+// patterns. Templates without a matcher use their name as a glob.
+type HostMatcher struct {
+	Type     string   `json:"type"`
+	Patterns []string `json:"patterns"`
+}
+
+// CompiledMatcher is a HostMatcher which is ready to be used
+type CompiledMatcher struct {
+	matcherType string
+	patterns    []string
+	regexes     []*regexp.Regexp
+
+	// The number of captures every match is guaranteed to have
+	captures int
+}
+
+func (m *HostMatcher) Validate() (error) {
+	_, err := m.Compile()
+	return err
+}
+
+// Compile prepares the matcher for use, returning a *ValidationError if it is invalid
+func (m *HostMatcher) Compile() (*CompiledMatcher, error) {
+	valid := false
+	for _, t := range AllMatcherTypes {
+		valid = valid || t == m.Type
+	}
+	if !valid {
+		return nil, &ValidationError{"Unknown matcher type: " + m.Type}
+	}
+	if len(m.Patterns) == 0 {
+		return nil, &ValidationError{"Matchers must have at least one pattern"}
+	}
+
+	c := &CompiledMatcher{matcherType: m.Type, patterns: m.Patterns}
+	for i, pattern := range m.Patterns {
+		if pattern == "" {
+			return nil, &ValidationError{"Matcher patterns cannot be empty"}
+		}
+
+		captures := 0
+		switch m.Type {
+		case MatcherGlob:
+			captures = strings.Count(pattern, "*")
+			break
+		case MatcherRegex:
+			r, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, &ValidationError{"Invalid regular expression " + pattern + ": " + err.Error()}
+			}
+			c.regexes = append(c.regexes, r)
+			captures = r.NumSubexp()
+			break
+		case MatcherSuffix:
+			captures = 1
+			break
+		}
+
+		if i == 0 || captures < c.captures {
+			c.captures = captures
+		}
+	}
+
+	return c, nil
+}
+
+// CompileTemplateMatcher compiles the matcher for the template, falling back to using the template's
+// name as a glob.
+func CompileTemplateMatcher(template string, matcher *HostMatcher) (*CompiledMatcher, error) {
+	if matcher == nil {
+		matcher = &HostMatcher{Type: MatcherGlob, Patterns: []string{template}}
+	}
+	return matcher.Compile()
+}
+
+// Captures is the number of captures every match is guaranteed to have
+func (c *CompiledMatcher) Captures() (int) {
+	return c.captures
+}
+
+// Match determines if the host matches, returning the text captured by the first pattern which
+// matched. Globs capture the text matched by each *, regular expressions capture their groups, and
+// suffixes capture the subdomain (which is empty when the host is the suffix itself).
+func (c *CompiledMatcher) Match(host string) ([]string, bool) {
+	for i, pattern := range c.patterns {
+		switch c.matcherType {
+		case MatcherGlob:
+			if captures, ok := util.GlobCaptures(pattern, host); ok {
+				return captures, true
+			}
+			break
+		case MatcherRegex:
+			if groups := c.regexes[i].FindStringSubmatch(host); groups != nil {
+				return groups[1:], true
+			}
+			break
+		case MatcherExact:
+			if host == pattern {
+				return []string{}, true
+			}
+			break
+		case MatcherSuffix:
+			if host == pattern {
+				return []string{""}, true
+			}
+			if strings.HasSuffix(host, "."+pattern) {
+				return []string{host[:len(host)-len(pattern)-1]}, true
+			}
+			break
+		}
+	}
+	return nil, false
+}
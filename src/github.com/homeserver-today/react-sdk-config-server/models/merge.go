@@ -0,0 +1,82 @@
+package models
+
+import (
+	"reflect"
+)
+
+// Merge strategies, which control how a value is combined with the value from earlier layers
+const MergeOverride = "override" // the value replaces the earlier one, but objects are merged key by key (the default)
+const MergeKeep = "keep"         // the earlier value is kept if there is one
+const MergeReplace = "replace"   // the value replaces the earlier one, without merging objects
+const MergeAppend = "append"     // arrays are added to the end of the earlier array
+const MergePrepend = "prepend"   // arrays are added to the start of the earlier array
+const MergeUnion = "union"       // items of arrays which aren't already in the earlier array are added to the end
+
+var AllMergeStrategies = []string{MergeOverride, MergeKeep, MergeReplace, MergeAppend, MergePrepend, MergeUnion}
+
+func IsValidMergeStrategy(strategy string) (bool) {
+	for _, s := range AllMergeStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// isArrayStrategy determines if the strategy combines arrays rather than picking one of them
+func isArrayStrategy(strategy string) (bool) {
+	return strategy == MergeAppend || strategy == MergePrepend || strategy == MergeUnion
+}
+
+// combineArrays joins the arrays using an array strategy. Alongside the combined array, the index of
+// every item in the result is given as either an index in existing (when fromExisting is true) or
+// in value.
+func combineArrays(strategy string, existing []interface{}, value []interface{}) ([]interface{}, []int, []bool) {
+	combined := make([]interface{}, 0, len(existing)+len(value))
+	indexes := make([]int, 0, cap(combined))
+	fromExisting := make([]bool, 0, cap(combined))
+
+	addExisting := func() {
+		for i, item := range existing {
+			combined = append(combined, copyValue(item))
+			indexes = append(indexes, i)
+			fromExisting = append(fromExisting, true)
+		}
+	}
+	addValue := func(onlyNew bool) {
+		for i, item := range value {
+			if onlyNew && containsValue(combined, item) {
+				continue
+			}
+			combined = append(combined, copyValue(item))
+			indexes = append(indexes, i)
+			fromExisting = append(fromExisting, false)
+		}
+	}
+
+	switch strategy {
+	case MergePrepend:
+		addValue(false)
+		addExisting()
+		break
+	case MergeUnion:
+		addExisting()
+		addValue(true)
+		break
+	default:
+		addExisting()
+		addValue(false)
+		break
+	}
+
+	return combined, indexes, fromExisting
+}
+
+func containsValue(items []interface{}, value interface{}) (bool) {
+	for _, item := range items {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
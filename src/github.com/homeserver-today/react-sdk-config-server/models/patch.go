@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/evanphx/json-patch"
+)
+
+const MergePatchContentType = "application/merge-patch+json"
+const JsonPatchContentType = "application/json-patch+json"
+
+var ErrUnsupportedPatch = errors.New("unsupported patch content type")
+
+// PatchError is returned when a patch cannot be applied to a config, such as when a JSON Patch
+// operation refers to a path which does not exist.
+type PatchError struct {
+	Message string
+}
+
+func (e *PatchError) Error() string {
+	return e.Message
+}
+
+// ConfigPatch is either a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) to be applied to a config
+type ConfigPatch struct {
+	contentType string
+	body        []byte
+	jsonPatch   jsonpatch.Patch
+}
+
+func NewConfigPatch(contentType string, body []byte) (*ConfigPatch, error) {
+	p := &ConfigPatch{
+		contentType: contentType,
+		body:        body,
+	}
+
+	var err error
+	switch contentType {
+	case MergePatchContentType:
+		var asObject map[string]interface{}
+		if err = json.Unmarshal(body, &asObject); err != nil || asObject == nil {
+			return nil, &PatchError{"Merge patch must be a JSON object"}
+		}
+		break
+	case JsonPatchContentType:
+		if p.jsonPatch, err = jsonpatch.DecodePatch(body); err != nil {
+			return nil, &PatchError{"Invalid JSON Patch: " + err.Error()}
+		}
+		break
+	default:
+		return nil, ErrUnsupportedPatch
+	}
+
+	return p, nil
+}
+
+func (p *ConfigPatch) Apply(config ReactConfig) (ReactConfig, error) {
+	original, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	if p.jsonPatch != nil {
+		patched, err = p.jsonPatch.Apply(original)
+	} else {
+		patched, err = jsonpatch.MergePatch(original, p.body)
+	}
+	if err != nil {
+		return nil, &PatchError{"Failed to apply patch: " + err.Error()}
+	}
+
+	newConfig := ReactConfig{}
+	err = json.Unmarshal(patched, &newConfig)
+	if err != nil {
+		return nil, &PatchError{"Patched config is not a JSON object"}
+	}
+
+	return newConfig, nil
+}
@@ -1,9 +1,89 @@
 package models
 
-import "github.com/imdario/mergo"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// UnsetKey lists the keys (nested keys separated by dots) which the config removes from templates
+const UnsetKey = "$unset"
 
 type ReactConfig map[string]interface{}
 
-func (c *ReactConfig) ApplyDefaults(defaults *ReactConfig) (error) {
-	return mergo.Merge(c, defaults)
+// ValidationError is returned when a config or its metadata cannot be used
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ForbiddenError is returned when a change goes beyond what the token making it may access
+type ForbiddenError struct {
+	Message string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Message
+}
+
+// ETag is a strong entity tag for the config, suitable for use in an ETag header
+func (c *ReactConfig) ETag() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(b)
+	return "\"" + hex.EncodeToString(hash[:16]) + "\"", nil
+}
+
+// HasKey determines if the config sets the key to something other than null. Nested keys are
+// separated by dots, although keys which contain dots themselves (like "hstoday.weight") are
+// matched first.
+func (c ReactConfig) HasKey(key string) (bool) {
+	if val, found := c[key]; found {
+		return val != nil
+	}
+
+	for i, ch := range key {
+		if ch != '.' {
+			continue
+		}
+		nested, isObj := c[key[:i]].(map[string]interface{})
+		if isObj && ReactConfig(nested).HasKey(key[i+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnsetKeys gets the keys which the config removes from templates
+func (c ReactConfig) UnsetKeys() ([]string, error) {
+	val, found := c[UnsetKey]
+	if !found || val == nil {
+		return []string{}, nil
+	}
+
+	list, isList := val.([]interface{})
+	if !isList {
+		return nil, &ValidationError{UnsetKey + " must be a list of keys"}
+	}
+	keys := make([]string, 0, len(list))
+	for _, item := range list {
+		key, isString := item.(string)
+		if !isString || key == "" {
+			return nil, &ValidationError{UnsetKey + " must be a list of keys"}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Validate checks that the config can be used, returning a *ValidationError if not
+func (c ReactConfig) Validate() (error) {
+	_, err := c.UnsetKeys()
+	return err
 }
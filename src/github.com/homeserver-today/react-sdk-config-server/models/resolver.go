@@ -0,0 +1,307 @@
+package models
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConfigLayer is one of the configs which make up a domain's complete config
+type ConfigLayer struct {
+	Source     string // the glob or domain the config is stored under
+	IsTemplate bool
+	Weight     int
+	Config     ReactConfig
+	Merge      map[string]string // merge strategies by key, from the config's metadata
+}
+
+// Resolution is the result of merging layers together
+type Resolution struct {
+	Config ReactConfig
+
+	// Sources mirrors the structure of the config, with the source of each value in place of the
+	// value itself. Objects are merged key by key, so their keys may come from different sources.
+	// Arrays which were combined from several sources have a list of sources, one per item.
+	Sources map[string]interface{}
+
+	// The layers which were merged, least important first
+	Layers []*ConfigLayer
+
+	// Keys which were removed by a layer's $unset list (and not set again by a later layer), with
+	// the source of the layer which removed them
+	Unset map[string]string
+
+	Conflicts []*TemplateConflict
+}
+
+// TemplateConflict is where templates with the same weight set a key to different values. The
+// conflict is settled by the order of the templates, but is probably not intended.
+type TemplateConflict struct {
+	Key       string   `json:"key"` // nested keys are separated by dots
+	Weight    int      `json:"weight"`
+	Templates []string `json:"templates"` // least important first
+	Winner    string   `json:"winner"`
+}
+
+// SortTemplates orders the templates with the least important first. Templates are ordered by
+// weight, then by how specific they are (fewer wildcards, then a longer literal suffix, is more
+// specific), then by name.
+func SortTemplates(templates []*ConfigLayer) {
+	sort.SliceStable(templates, func(i int, j int) bool {
+		a := templates[i]
+		b := templates[j]
+		if a.Weight != b.Weight {
+			return a.Weight < b.Weight
+		}
+
+		aWildcards := strings.Count(a.Source, "*")
+		bWildcards := strings.Count(b.Source, "*")
+		if aWildcards != bWildcards {
+			return aWildcards > bWildcards
+		}
+
+		aSuffix := len(a.Source) - strings.LastIndex(a.Source, "*") - 1
+		bSuffix := len(b.Source) - strings.LastIndex(b.Source, "*") - 1
+		if aSuffix != bSuffix {
+			return aSuffix < bSuffix
+		}
+
+		return a.Source < b.Source
+	})
+}
+
+type resolver struct {
+	resolution *Resolution
+	layers     map[string]*ConfigLayer
+}
+
+// ResolveLayers merges the layers, which must be ordered with the least important first. Values in
+// later layers replace those in earlier layers, except for objects which are merged and nulls which
+// are ignored. A layer's merge strategies can change this for particular keys, and its $unset list
+// removes keys set by earlier layers. The layers are not modified.
+func ResolveLayers(layers []*ConfigLayer) (*Resolution) {
+	r := &resolver{
+		resolution: &Resolution{
+			Config:    ReactConfig{},
+			Sources:   make(map[string]interface{}),
+			Layers:    layers,
+			Unset:     make(map[string]string),
+			Conflicts: make([]*TemplateConflict, 0),
+		},
+		layers: make(map[string]*ConfigLayer),
+	}
+
+	for _, layer := range layers {
+		r.layers[layer.Source] = layer
+
+		// Invalid lists are rejected when the config is stored, so can only be ignored here
+		unsetKeys, _ := layer.Config.UnsetKeys()
+		for _, key := range unsetKeys {
+			if unsetKey(r.resolution.Config, r.resolution.Sources, key) {
+				r.resolution.Unset[key] = layer.Source
+			}
+		}
+
+		r.merge("", r.resolution.Config, r.resolution.Sources, layer.Config, layer)
+	}
+
+	for key := range r.resolution.Unset {
+		if r.resolution.Config.HasKey(key) {
+			delete(r.resolution.Unset, key)
+		}
+	}
+
+	return r.resolution
+}
+
+// unsetKey removes the key from the config and its sources, returning whether it was there to be
+// removed. Keys are matched in the same way as ReactConfig.HasKey.
+func unsetKey(config map[string]interface{}, sources map[string]interface{}, key string) (bool) {
+	if _, found := config[key]; found {
+		delete(config, key)
+		delete(sources, key)
+		return true
+	}
+
+	for i, ch := range key {
+		if ch != '.' {
+			continue
+		}
+		nested, isObj := config[key[:i]].(map[string]interface{})
+		if !isObj {
+			continue
+		}
+		nestedSources, _ := sources[key[:i]].(map[string]interface{})
+		if unsetKey(nested, nestedSources, key[i+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *resolver) merge(path string, dst map[string]interface{}, sources map[string]interface{}, src map[string]interface{}, layer *ConfigLayer) {
+	// Sorted so that conflicts are always reported in the same order
+	keys := make([]string, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if path == "" && k == UnsetKey {
+			continue
+		}
+
+		v := src[k]
+		existing, exists := dst[k]
+		strategy := layer.Merge[path+k]
+
+		if v == nil {
+			// A null doesn't replace anything, but is kept if there's nothing else
+			if !exists {
+				dst[k] = nil
+				sources[k] = layer.Source
+			}
+			continue
+		}
+
+		if strategy == MergeKeep && exists && existing != nil {
+			continue
+		}
+
+		srcObj, srcIsObj := v.(map[string]interface{})
+		dstObj, dstIsObj := existing.(map[string]interface{})
+		if srcIsObj && dstIsObj && strategy != MergeReplace {
+			objSources, _ := sources[k].(map[string]interface{})
+			r.merge(path+k+".", dstObj, objSources, srcObj, layer)
+			continue
+		}
+
+		srcArr, srcIsArr := v.([]interface{})
+		dstArr, dstIsArr := existing.([]interface{})
+		if srcIsArr && dstIsArr && isArrayStrategy(strategy) {
+			combined, indexes, fromExisting := combineArrays(strategy, dstArr, srcArr)
+			dst[k] = combined
+			sources[k] = combinedSources(sources[k], indexes, fromExisting, layer.Source)
+			continue
+		}
+
+		if exists && existing != nil && !reflect.DeepEqual(existing, v) {
+			r.checkConflict(path+k, sources[k], layer)
+		}
+
+		dst[k] = copyValue(v)
+		sources[k] = sourcesFor(v, layer.Source)
+	}
+}
+
+// checkConflict records a conflict if the value being replaced came from a template with the same weight
+func (r *resolver) checkConflict(key string, replacedSources interface{}, layer *ConfigLayer) {
+	if !layer.IsTemplate {
+		return
+	}
+
+	for _, source := range flattenSources(replacedSources) {
+		replaced := r.layers[source]
+		if replaced == nil || !replaced.IsTemplate || replaced.Weight != layer.Weight {
+			continue
+		}
+
+		r.resolution.Conflicts = append(r.resolution.Conflicts, &TemplateConflict{
+			Key:       key,
+			Weight:    layer.Weight,
+			Templates: []string{replaced.Source, layer.Source},
+			Winner:    layer.Source,
+		})
+	}
+}
+
+func flattenSources(sources interface{}) ([]string) {
+	if source, ok := sources.(string); ok {
+		return []string{source}
+	}
+
+	var children []interface{}
+	switch s := sources.(type) {
+	case map[string]interface{}:
+		for _, v := range s {
+			children = append(children, v)
+		}
+		break
+	case []interface{}:
+		children = s
+		break
+	}
+
+	seen := make(map[string]bool)
+	results := make([]string, 0)
+	for _, v := range children {
+		for _, source := range flattenSources(v) {
+			if !seen[source] {
+				seen[source] = true
+				results = append(results, source)
+			}
+		}
+	}
+	sort.Strings(results)
+	return results
+}
+
+// combinedSources builds the sources of an array which was made by combineArrays. The sources are
+// only listed per item when they differ.
+func combinedSources(existingSources interface{}, indexes []int, fromExisting []bool, source string) (interface{}) {
+	existingList, isList := existingSources.([]interface{})
+
+	itemSources := make([]interface{}, len(indexes))
+	allSame := true
+	for i, index := range indexes {
+		if !fromExisting[i] {
+			itemSources[i] = source
+		} else if isList && index < len(existingList) {
+			itemSources[i] = existingList[index]
+		} else {
+			itemSources[i] = existingSources
+		}
+		if itemSources[i] != itemSources[0] {
+			allSame = false
+		}
+	}
+
+	if allSame && len(itemSources) > 0 {
+		return itemSources[0]
+	}
+	return itemSources
+}
+
+// sourcesFor builds the sources of a value which came entirely from one place
+func sourcesFor(value interface{}, source string) (interface{}) {
+	obj, isObj := value.(map[string]interface{})
+	if !isObj {
+		return source
+	}
+
+	sources := make(map[string]interface{})
+	for k, v := range obj {
+		sources[k] = sourcesFor(v, source)
+	}
+	return sources
+}
+
+func copyValue(value interface{}) (interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			c[k] = copyValue(val)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, val := range v {
+			c[i] = copyValue(val)
+		}
+		return c
+	default:
+		return v
+	}
+}
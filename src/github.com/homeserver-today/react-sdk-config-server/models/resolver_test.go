@@ -0,0 +1,333 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func parseConfig(t *testing.T, s string) (ReactConfig) {
+	config := ReactConfig{}
+	if err := json.Unmarshal([]byte(s), &config); err != nil {
+		t.Fatalf("invalid test config %s: %v", s, err)
+	}
+	return config
+}
+
+func assertConfig(t *testing.T, actual ReactConfig, expected string) {
+	if !reflect.DeepEqual(map[string]interface{}(actual), map[string]interface{}(parseConfig(t, expected))) {
+		b, _ := json.Marshal(actual)
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestResolveLayersMergeStrategies(t *testing.T) {
+	base := `{"brand": "Base", "roomDirectory": {"servers": ["a.org", "b.org"], "default": "a.org"}}`
+	overlay := `{"brand": "Overlay", "roomDirectory": {"servers": ["b.org", "c.org"]}}`
+
+	tests := []struct {
+		name     string
+		merge    map[string]string
+		expected string
+	}{
+		{
+			name:     "default overrides values and merges objects",
+			merge:    nil,
+			expected: `{"brand": "Overlay", "roomDirectory": {"servers": ["b.org", "c.org"], "default": "a.org"}}`,
+		},
+		{
+			name:     "override",
+			merge:    map[string]string{"roomDirectory.servers": MergeOverride},
+			expected: `{"brand": "Overlay", "roomDirectory": {"servers": ["b.org", "c.org"], "default": "a.org"}}`,
+		},
+		{
+			name:     "keep",
+			merge:    map[string]string{"brand": MergeKeep, "roomDirectory.servers": MergeKeep},
+			expected: `{"brand": "Base", "roomDirectory": {"servers": ["a.org", "b.org"], "default": "a.org"}}`,
+		},
+		{
+			name:     "replace",
+			merge:    map[string]string{"roomDirectory": MergeReplace},
+			expected: `{"brand": "Overlay", "roomDirectory": {"servers": ["b.org", "c.org"]}}`,
+		},
+		{
+			name:     "append",
+			merge:    map[string]string{"roomDirectory.servers": MergeAppend},
+			expected: `{"brand": "Overlay", "roomDirectory": {"servers": ["a.org", "b.org", "b.org", "c.org"], "default": "a.org"}}`,
+		},
+		{
+			name:     "prepend",
+			merge:    map[string]string{"roomDirectory.servers": MergePrepend},
+			expected: `{"brand": "Overlay", "roomDirectory": {"servers": ["b.org", "c.org", "a.org", "b.org"], "default": "a.org"}}`,
+		},
+		{
+			name:     "union",
+			merge:    map[string]string{"roomDirectory.servers": MergeUnion},
+			expected: `{"brand": "Overlay", "roomDirectory": {"servers": ["a.org", "b.org", "c.org"], "default": "a.org"}}`,
+		},
+		{
+			name:     "array strategies replace values which aren't arrays",
+			merge:    map[string]string{"brand": MergeAppend},
+			expected: `{"brand": "Overlay", "roomDirectory": {"servers": ["b.org", "c.org"], "default": "a.org"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			baseConfig := parseConfig(t, base)
+			layers := []*ConfigLayer{
+				{Source: "*.t2bot.io", IsTemplate: true, Config: baseConfig},
+				{Source: "t2bot.io", Config: parseConfig(t, overlay), Merge: test.merge},
+			}
+
+			resolution := ResolveLayers(layers)
+			assertConfig(t, resolution.Config, test.expected)
+			assertConfig(t, baseConfig, base)
+		})
+	}
+}
+
+func TestResolveLayersNestedSources(t *testing.T) {
+	layers := []*ConfigLayer{
+		{Source: "*.t2bot.io", IsTemplate: true, Config: parseConfig(t, `{"roomDirectory": {"servers": ["a.org"], "default": "a.org"}}`)},
+		{Source: "t2bot.io", Config: parseConfig(t, `{"roomDirectory": {"servers": ["b.org"]}}`), Merge: map[string]string{"roomDirectory.servers": MergeAppend}},
+	}
+
+	resolution := ResolveLayers(layers)
+	expected := map[string]interface{}{
+		"roomDirectory": map[string]interface{}{
+			"servers": []interface{}{"*.t2bot.io", "t2bot.io"},
+			"default": "*.t2bot.io",
+		},
+	}
+	if !reflect.DeepEqual(resolution.Sources, expected) {
+		t.Errorf("expected sources %v, got %v", expected, resolution.Sources)
+	}
+}
+
+func TestResolveLayersNulls(t *testing.T) {
+	layers := []*ConfigLayer{
+		{Source: "*.t2bot.io", IsTemplate: true, Config: parseConfig(t, `{"brand": "Base", "welcomeUserId": null}`)},
+		{Source: "t2bot.io", Config: parseConfig(t, `{"brand": null}`)},
+	}
+
+	resolution := ResolveLayers(layers)
+	assertConfig(t, resolution.Config, `{"brand": "Base", "welcomeUserId": null}`)
+}
+
+func TestResolveLayersUnset(t *testing.T) {
+	tests := []struct {
+		name          string
+		layers        []string
+		expected      string
+		expectedUnset map[string]string
+	}{
+		{
+			name: "top level and nested keys",
+			layers: []string{
+				`{"brand": "Base", "roomDirectory": {"servers": ["a.org"], "default": "a.org"}}`,
+				`{"$unset": ["brand", "roomDirectory.servers"]}`,
+			},
+			expected:      `{"roomDirectory": {"default": "a.org"}}`,
+			expectedUnset: map[string]string{"brand": "layer1", "roomDirectory.servers": "layer1"},
+		},
+		{
+			name: "keys containing dots",
+			layers: []string{
+				`{"features": {"feature_pinning.labs": true, "feature_other": true}}`,
+				`{"$unset": ["features.feature_pinning.labs"]}`,
+			},
+			expected:      `{"features": {"feature_other": true}}`,
+			expectedUnset: map[string]string{"features.feature_pinning.labs": "layer1"},
+		},
+		{
+			name: "keys which aren't set",
+			layers: []string{
+				`{"brand": "Base"}`,
+				`{"$unset": ["roomDirectory.servers"]}`,
+			},
+			expected:      `{"brand": "Base"}`,
+			expectedUnset: map[string]string{},
+		},
+		{
+			name: "keys set again by a later layer",
+			layers: []string{
+				`{"brand": "Base"}`,
+				`{"$unset": ["brand"]}`,
+				`{"brand": "Again"}`,
+			},
+			expected:      `{"brand": "Again"}`,
+			expectedUnset: map[string]string{},
+		},
+		{
+			name: "only what earlier layers set",
+			layers: []string{
+				`{"brand": "Base"}`,
+				`{"$unset": ["brand"], "brand": "Own"}`,
+			},
+			expected:      `{"brand": "Own"}`,
+			expectedUnset: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layers := make([]*ConfigLayer, 0, len(test.layers))
+			for i, config := range test.layers {
+				layers = append(layers, &ConfigLayer{Source: "layer" + strconv.Itoa(i), Config: parseConfig(t, config)})
+			}
+
+			resolution := ResolveLayers(layers)
+			assertConfig(t, resolution.Config, test.expected)
+			if !reflect.DeepEqual(resolution.Unset, test.expectedUnset) {
+				t.Errorf("expected unset %v, got %v", test.expectedUnset, resolution.Unset)
+			}
+		})
+	}
+}
+
+func TestResolveLayersConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		layers   []*ConfigLayer
+		expected []*TemplateConflict
+	}{
+		{
+			name: "same weight, different values",
+			layers: []*ConfigLayer{
+				{Source: "*.t2bot.io", IsTemplate: true, Weight: 1, Config: ReactConfig{"brand": "A"}},
+				{Source: "*.io", IsTemplate: true, Weight: 1, Config: ReactConfig{"brand": "B"}},
+			},
+			expected: []*TemplateConflict{{Key: "brand", Weight: 1, Templates: []string{"*.t2bot.io", "*.io"}, Winner: "*.io"}},
+		},
+		{
+			name: "same weight, nested keys",
+			layers: []*ConfigLayer{
+				{Source: "*.t2bot.io", IsTemplate: true, Config: ReactConfig{"roomDirectory": map[string]interface{}{"servers": []interface{}{"a.org"}}}},
+				{Source: "*.io", IsTemplate: true, Config: ReactConfig{"roomDirectory": map[string]interface{}{"servers": []interface{}{"b.org"}}}},
+			},
+			expected: []*TemplateConflict{{Key: "roomDirectory.servers", Weight: 0, Templates: []string{"*.t2bot.io", "*.io"}, Winner: "*.io"}},
+		},
+		{
+			name: "same weight, same values",
+			layers: []*ConfigLayer{
+				{Source: "*.t2bot.io", IsTemplate: true, Config: ReactConfig{"brand": "A"}},
+				{Source: "*.io", IsTemplate: true, Config: ReactConfig{"brand": "A"}},
+			},
+			expected: []*TemplateConflict{},
+		},
+		{
+			name: "different weights",
+			layers: []*ConfigLayer{
+				{Source: "*.t2bot.io", IsTemplate: true, Weight: 1, Config: ReactConfig{"brand": "A"}},
+				{Source: "*.io", IsTemplate: true, Weight: 2, Config: ReactConfig{"brand": "B"}},
+			},
+			expected: []*TemplateConflict{},
+		},
+		{
+			name: "domain replacing a template",
+			layers: []*ConfigLayer{
+				{Source: "*.t2bot.io", IsTemplate: true, Config: ReactConfig{"brand": "A"}},
+				{Source: "t2bot.io", Config: ReactConfig{"brand": "B"}},
+			},
+			expected: []*TemplateConflict{},
+		},
+		{
+			name: "combined arrays",
+			layers: []*ConfigLayer{
+				{Source: "*.t2bot.io", IsTemplate: true, Config: ReactConfig{"servers": []interface{}{"a.org"}}},
+				{Source: "*.io", IsTemplate: true, Config: ReactConfig{"servers": []interface{}{"b.org"}}, Merge: map[string]string{"servers": MergeUnion}},
+			},
+			expected: []*TemplateConflict{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolution := ResolveLayers(test.layers)
+			if !reflect.DeepEqual(resolution.Conflicts, test.expected) {
+				b, _ := json.Marshal(resolution.Conflicts)
+				t.Errorf("unexpected conflicts: %s", b)
+			}
+		})
+	}
+}
+
+func TestCombineArrays(t *testing.T) {
+	existing := []interface{}{"a", "b"}
+	value := []interface{}{"b", "c"}
+
+	tests := []struct {
+		strategy             string
+		expected             []interface{}
+		expectedIndexes      []int
+		expectedFromExisting []bool
+	}{
+		{MergeAppend, []interface{}{"a", "b", "b", "c"}, []int{0, 1, 0, 1}, []bool{true, true, false, false}},
+		{MergePrepend, []interface{}{"b", "c", "a", "b"}, []int{0, 1, 0, 1}, []bool{false, false, true, true}},
+		{MergeUnion, []interface{}{"a", "b", "c"}, []int{0, 1, 1}, []bool{true, true, false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.strategy, func(t *testing.T) {
+			combined, indexes, fromExisting := combineArrays(test.strategy, existing, value)
+			if !reflect.DeepEqual(combined, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, combined)
+			}
+			if !reflect.DeepEqual(indexes, test.expectedIndexes) {
+				t.Errorf("expected indexes %v, got %v", test.expectedIndexes, indexes)
+			}
+			if !reflect.DeepEqual(fromExisting, test.expectedFromExisting) {
+				t.Errorf("expected fromExisting %v, got %v", test.expectedFromExisting, fromExisting)
+			}
+		})
+	}
+}
+
+func TestCombineArraysUnionOfObjects(t *testing.T) {
+	existing := []interface{}{map[string]interface{}{"url": "a"}}
+	value := []interface{}{map[string]interface{}{"url": "a"}, map[string]interface{}{"url": "b"}}
+
+	combined, _, _ := combineArrays(MergeUnion, existing, value)
+	expected := []interface{}{map[string]interface{}{"url": "a"}, map[string]interface{}{"url": "b"}}
+	if !reflect.DeepEqual(combined, expected) {
+		t.Errorf("expected %v, got %v", expected, combined)
+	}
+}
+
+func TestSortTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		sources  []string
+		weights  map[string]int
+		expected []string
+	}{
+		{"weight first", []string{"*.t2bot.io", "*"}, map[string]int{"*": 10}, []string{"*.t2bot.io", "*"}},
+		{"more wildcards first", []string{"*.t2bot.io", "*.*.t2bot.io", "*"}, nil, []string{"*.*.t2bot.io", "*", "*.t2bot.io"}},
+		{"shorter suffix first", []string{"*.chat.t2bot.io", "*.io", "*.t2bot.io"}, nil, []string{"*.io", "*.t2bot.io", "*.chat.t2bot.io"}},
+		{"suffix after the last wildcard", []string{"*.t2bot.*", "chat.*.io"}, nil, []string{"*.t2bot.*", "chat.*.io"}},
+		{"then by name", []string{"*.t2bot.io", "*.t2bot.de", "*.t2bot.ca"}, nil, []string{"*.t2bot.ca", "*.t2bot.de", "*.t2bot.io"}},
+		{"named templates by name", []string{"@chat", "@base"}, nil, []string{"@base", "@chat"}},
+		{"named templates by weight", []string{"@base", "@chat"}, map[string]int{"@base": 1}, []string{"@chat", "@base"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			templates := make([]*ConfigLayer, 0, len(test.sources))
+			for _, source := range test.sources {
+				templates = append(templates, &ConfigLayer{Source: source, IsTemplate: true, Weight: test.weights[source]})
+			}
+
+			SortTemplates(templates)
+
+			sorted := make([]string, 0, len(templates))
+			for _, template := range templates {
+				sorted = append(sorted, template.Source)
+			}
+			if !reflect.DeepEqual(sorted, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, sorted)
+			}
+		})
+	}
+}
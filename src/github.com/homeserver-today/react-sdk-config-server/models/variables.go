@@ -0,0 +1,114 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DomainVariable is the name of the variable holding the domain the config is being calculated for
+const DomainVariable = "domain"
+
+// Variables are substituted into the string values of configs, where they are written as {{name}}.
+// Writing \{{name}} escapes the variable, leaving {{name}} in the value.
+type Variables map[string]string
+
+// NewVariables builds the variables for a config stored under source when it is used for a domain.
+// The text matched by each wildcard in source is available as {{1}}, {{2}} and so on. Server
+// variables cannot replace these, or the domain.
+func NewVariables(server map[string]string, domain string, captures []string) (Variables) {
+	v := Variables{}
+	for name, value := range server {
+		v[name] = value
+	}
+	for i, capture := range captures {
+		v[strconv.Itoa(i+1)] = capture
+	}
+	v[DomainVariable] = domain
+	return v
+}
+
+// ValidateVariables checks that the config only uses variables which will be known when it is used,
+// returning a *ValidationError if not. Captures is the number of captures the config's matcher has.
+func ValidateVariables(config ReactConfig, captures int, server map[string]string) (error) {
+	_, unknown := NewVariables(server, "", make([]string, captures)).Interpolate(config)
+	if len(unknown) > 0 {
+		return &ValidationError{"Unknown variables: " + strings.Join(unknown, ", ")}
+	}
+	return nil
+}
+
+// Interpolate returns a copy of the config with the variables substituted, along with the names of
+// any unknown variables. Unknown variables are left as they are.
+func (v Variables) Interpolate(config ReactConfig) (ReactConfig, []string) {
+	unknown := make(map[string]bool)
+	result := v.interpolateValue(map[string]interface{}(config), unknown).(map[string]interface{})
+
+	names := make([]string, 0, len(unknown))
+	for name := range unknown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return result, names
+}
+
+func (v Variables) interpolateValue(value interface{}, unknown map[string]bool) (interface{}) {
+	switch val := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			c[k] = v.interpolateValue(item, unknown)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(val))
+		for i, item := range val {
+			c[i] = v.interpolateValue(item, unknown)
+		}
+		return c
+	case string:
+		return v.interpolateString(val, unknown)
+	default:
+		return val
+	}
+}
+
+func (v Variables) interpolateString(s string, unknown map[string]bool) (string) {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			break
+		}
+
+		if start > 0 && s[start-1] == '\\' {
+			b.WriteString(s[:start-1])
+			b.WriteString("{{")
+			s = s[start+2:]
+			continue
+		}
+
+		end := strings.Index(s[start+2:], "}}")
+		if end < 0 {
+			break
+		}
+		end += start + 2
+
+		b.WriteString(s[:start])
+		name := strings.TrimSpace(s[start+2 : end])
+		if value, found := v[name]; found {
+			b.WriteString(value)
+		} else {
+			unknown[name] = true
+			b.WriteString(s[start : end+2])
+		}
+		s = s[end+2:]
+	}
+	b.WriteString(s)
+
+	return b.String()
+}
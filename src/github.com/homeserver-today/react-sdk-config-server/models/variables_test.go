@@ -0,0 +1,87 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterpolateStrings(t *testing.T) {
+	server := map[string]string{"brand": "Riot", "domain": "server.example.org", "1": "server"}
+	v := NewVariables(server, "chat.t2bot.io", []string{"chat"})
+
+	tests := []struct {
+		name            string
+		value           string
+		expected        string
+		expectedUnknown []string
+	}{
+		{"no variables", "plain text", "plain text", []string{}},
+		{"server variable", "{{brand}} Web", "Riot Web", []string{}},
+		{"domain wins over the server", "https://{{domain}}", "https://chat.t2bot.io", []string{}},
+		{"capture wins over the server", "{{1}}.example.org", "chat.example.org", []string{}},
+		{"whitespace is trimmed", "{{ brand }}", "Riot", []string{}},
+		{"several variables", "{{brand}} for {{domain}}", "Riot for chat.t2bot.io", []string{}},
+		{"escaped", `\{{brand}}`, "{{brand}}", []string{}},
+		{"escaped next to a variable", `\{{brand}} is {{brand}}`, "{{brand}} is Riot", []string{}},
+		{"unknown variables are left", "{{missing}} and {{other}}", "{{missing}} and {{other}}", []string{"missing", "other"}},
+		{"unknown capture", "{{2}}", "{{2}}", []string{"2"}},
+		{"unclosed", "{{brand", "{{brand", []string{}},
+		{"unclosed after a variable", "{{brand}} {{", "Riot {{", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interpolated, unknown := v.Interpolate(ReactConfig{"value": test.value})
+			if actual := interpolated["value"]; actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+			if !reflect.DeepEqual(unknown, test.expectedUnknown) {
+				t.Errorf("expected unknown variables %v, got %v", test.expectedUnknown, unknown)
+			}
+		})
+	}
+}
+
+func TestInterpolateNested(t *testing.T) {
+	v := NewVariables(map[string]string{"brand": "Riot"}, "t2bot.io", nil)
+	config := parseConfig(t, `{
+		"brand": "{{brand}}",
+		"default_server_config": {"m.homeserver": {"base_url": "https://{{domain}}", "port": 443}},
+		"servers": ["{{domain}}", {"name": "{{brand}}"}, true, null],
+		"unknown": ["{{zzz}}", {"deep": "{{aaa}}"}]
+	}`)
+
+	interpolated, unknown := v.Interpolate(config)
+	assertConfig(t, interpolated, `{
+		"brand": "Riot",
+		"default_server_config": {"m.homeserver": {"base_url": "https://t2bot.io", "port": 443}},
+		"servers": ["t2bot.io", {"name": "Riot"}, true, null],
+		"unknown": ["{{zzz}}", {"deep": "{{aaa}}"}]
+	}`)
+	if !reflect.DeepEqual(unknown, []string{"aaa", "zzz"}) {
+		t.Errorf("expected sorted unknown variables, got %v", unknown)
+	}
+
+	// The original config must be left alone
+	assertConfig(t, config, `{
+		"brand": "{{brand}}",
+		"default_server_config": {"m.homeserver": {"base_url": "https://{{domain}}", "port": 443}},
+		"servers": ["{{domain}}", {"name": "{{brand}}"}, true, null],
+		"unknown": ["{{zzz}}", {"deep": "{{aaa}}"}]
+	}`)
+}
+
+func TestValidateVariables(t *testing.T) {
+	server := map[string]string{"brand": "Riot"}
+	config := ReactConfig{"brand": "{{brand}} on {{domain}}", "host": "{{1}}.{{2}}.example.org"}
+
+	if err := ValidateVariables(config, 2, server); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if _, ok := ValidateVariables(config, 1, server).(*ValidationError); !ok {
+		t.Error("expected a *ValidationError for a capture the matcher doesn't have")
+	}
+	if _, ok := ValidateVariables(config, 2, nil).(*ValidationError); !ok {
+		t.Error("expected a *ValidationError for an unknown server variable")
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+// Events sent to webhooks
+const WebhookEventConfigChanged = "config_changed"
+
+// States of a webhook delivery
+const DeliveryPending = "pending"     // waiting to be sent, or to be retried
+const DeliveryDelivered = "delivered" // the receiver accepted it
+const DeliveryFailed = "failed"       // every attempt failed
+
+// ConfigChange describes a change to a stored config, for telling webhooks about it
+type ConfigChange struct {
+	Domain    string         `json:"domain"`
+	Action    string         `json:"action"`
+	Actor     string         `json:"actor"`
+	ChangedTs int64          `json:"changed_ts"`
+	Diff      []*AuditChange `json:"diff"`
+
+	// The domains whose complete config may have changed. For templates, this is every domain with a
+	// stored config or which has recently been served that used the template before or after the change.
+	AffectedDomains []string `json:"affected_domains"`
+}
+
+// WebhookPayload is the body sent to webhooks. The ID is the same for every attempt to deliver it.
+type WebhookPayload struct {
+	Id    string `json:"id"`
+	Event string `json:"event"`
+	ConfigChange
+}
+
+// WebhookDelivery records an attempt to tell a webhook about a change
+type WebhookDelivery struct {
+	Id         string `json:"id"`
+	Url        string `json:"url"`
+	Domain     string `json:"domain"`
+	Action     string `json:"action"`
+	Status     string `json:"status"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"last_status_code,omitempty"`
+	Error      string `json:"last_error,omitempty"`
+	CreatedTs  int64  `json:"created_ts"`
+	UpdatedTs  int64  `json:"updated_ts"`
+}
@@ -2,49 +2,109 @@ package storage
 
 import (
 	"time"
-	"github.com/patrickmn/go-cache"
 	"context"
 	"github.com/sirupsen/logrus"
 	"sync"
 	"github.com/homeserver-today/react-sdk-config-server/models"
-	"github.com/ryanuber/go-glob"
-	"database/sql"
 	"strings"
 	"sort"
 	"github.com/homeserver-today/react-sdk-config-server/metrics"
+	"strconv"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+	"reflect"
+	"encoding/json"
+	serverConfig "github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/homeserver-today/react-sdk-config-server/cachebus"
 )
 
-const cacheExpiration = 1 * time.Hour
-const cleanupInterval = 2 * time.Hour
+// Expired entries are removed this often, as well as when they are looked up
+const cleanupInterval = 10 * time.Minute
 const domainPrefix = "domain_"
+const templatePrefix = "template_"
 const globListKey = "globs"
 const metricsCacheName = "configs"
 
 type configCacheFactory struct {
-	cache *cache.Cache
+	cache *lruCache
 }
 
 type configCache struct {
-	cache *cache.Cache
-	ctx   context.Context
-	log   *logrus.Entry
+	cache        *lruCache
+	ctx          context.Context
+	log          *logrus.Entry
+	actor        string
+	token        *models.ApiToken
+	remoteAddr   string
+	precondition *Precondition
+
+	// Changes which are about to be made, used in place of what is stored when working out what the
+	// changes will do. A nil record means the config is being deleted.
+	pending map[string]*ConfigRecord
+}
+
+// ResolvedConfig is the complete config for a domain, after any templates have been applied
+type ResolvedConfig struct {
+	Config *models.ReactConfig
+
+	// The time (in milliseconds) that the stored configs used last changed, or that a stored config last
+	// stopped applying to domains, whichever is later. This is 0 if nothing stored applies to the domain.
+	LastModified int64
+
+	ETag string
+
+	// The templates used to calculate the config, including ones which matched but were disabled or
+	// missing, so that it can be invalidated when they change
+	templates map[string]bool
+
+	// Set when nothing stored applies to the domain, such as for unknown hosts
+	negative bool
+}
+
+func newResolvedConfig(config *models.ReactConfig, lastModified int64) (*ResolvedConfig, error) {
+	etag, err := config.ETag()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResolvedConfig{
+		Config:       config,
+		LastModified: lastModified,
+		ETag:         etag,
+	}, nil
 }
 
 var cacheInstance *configCacheFactory
 var cacheSingletonLock = &sync.Once{}
 
+// Held while changing configs so that read-modify-write operations (like patches) are atomic
+var writeLock = &sync.Mutex{}
+
 func getBaseCache() (*configCacheFactory) {
 	if cacheInstance == nil {
 		cacheSingletonLock.Do(func() {
+			cacheConfig := serverConfig.Get().Cache
+			baseCache := newLruCache(time.Duration(cacheConfig.TtlSeconds)*time.Second, cacheConfig.MaxEntries, cacheConfig.Enabled)
+			baseCache.onEvict = func(count int) {
+				metrics.IncCacheEviction(metricsCacheName, count)
+			}
+
 			cacheInstance = &configCacheFactory{
-				cache: cache.New(cacheExpiration, cleanupInterval),
+				cache: baseCache,
 			}
+			go cacheInstance.cleanup()
 		})
 	}
 
 	return cacheInstance
 }
 
+func (f *configCacheFactory) cleanup() {
+	for range time.Tick(cleanupInterval) {
+		f.cache.DeleteExpired()
+		updateCacheCount()
+	}
+}
+
 func updateCacheCount() {
 	metrics.SetCacheCount(metricsCacheName, getBaseCache().cache.ItemCount())
 }
@@ -57,127 +117,1154 @@ func GetForwardingCache(ctx context.Context, log *logrus.Entry) (*configCache) {
 	}
 }
 
+// WithActor sets who is responsible for any changes made through the cache, for the revision history.
+func (c *configCache) WithActor(actor string) (*configCache) {
+	c.actor = actor
+	return c
+}
+
+// WithToken sets the API token responsible for any changes made through the cache. Templates can
+// only be given matchers for hosts which the token may write to.
+func (c *configCache) WithToken(token *models.ApiToken) (*configCache) {
+	c.actor = token.Name
+	c.token = token
+	return c
+}
+
+// WithRemoteAddress sets where changes made through the cache came from, for the audit log.
+func (c *configCache) WithRemoteAddress(remoteAddr string) (*configCache) {
+	c.remoteAddr = remoteAddr
+	return c
+}
+
+// WithPrecondition requires that the stored config be in a given state for changes to happen. If
+// the precondition is not met, writes fail with ErrPreconditionFailed.
+func (c *configCache) WithPrecondition(precondition *Precondition) (*configCache) {
+	c.precondition = precondition
+	return c
+}
+
+// GetStoredConfig gets the domain's config as it is stored, without applying any templates
+func (c *configCache) GetStoredConfig(domain string) (*ConfigRecord, error) {
+	return GetStore().GetConfig(c.ctx, domain)
+}
+
 func (c *configCache) GetConfig(domain string) (*models.ReactConfig, error) {
-	config, found := c.cache.Get(domainPrefix + domain)
+	resolved, err := c.GetResolvedConfig(domain)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.Config, nil
+}
+
+// GetResolvedConfig gets the complete config for the domain along with information about where it
+// came from. Glob domains are returned as they are stored.
+func (c *configCache) GetResolvedConfig(domain string) (*ResolvedConfig, error) {
+	key := domainPrefix + domain
+	if models.IsTemplate(domain) {
+		key = templatePrefix + domain
+	}
+
+	config, found := c.cache.Get(key)
 	if found {
 		metrics.IncCacheHit(metricsCacheName)
-		return config.(*models.ReactConfig), nil
+		return config.(*ResolvedConfig), nil
 	} else {
 		metrics.IncCacheMiss(metricsCacheName)
 	}
 
-	if strings.Contains(domain, "*") {
-		dbConfig, err := GetDatabase().GetConfig(c.ctx, domain)
-		if err == sql.ErrNoRows {
-			dbConfig = models.ReactConfig{}
-		} else if err != nil {
-			return nil, err
-		}
+	var resolved *ResolvedConfig
+	var err error
+	if models.IsTemplate(domain) {
+		resolved, err = c.getTemplateConfig(domain)
+	} else {
+		resolved, err = c.calculateConfig(domain)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.cacheResolved(key, resolved)
+	return resolved, nil
+}
+
+// getTemplateConfig gets the template's config as it is stored
+func (c *configCache) getTemplateConfig(template string) (*ResolvedConfig, error) {
+	dbConfig := models.ReactConfig{}
+	lastModified := int64(0)
+	record, err := GetStore().GetConfig(c.ctx, template)
+	if err == nil {
+		dbConfig = record.Config
+		lastModified = record.UpdatedTs
+	} else if err != ErrConfigNotFound {
+		return nil, err
+	}
 
-		return &dbConfig, nil
+	resolved, err := newResolvedConfig(&dbConfig, lastModified)
+	if err != nil {
+		return nil, err
+	}
+	resolved.negative = record == nil
+	return resolved, nil
+}
+
+// cacheResolved caches the config. Configs for domains which nothing stored applies to are cached for
+// less time (if at all), so that lots of unknown hosts don't fill the cache.
+func (c *configCache) cacheResolved(key string, resolved *ResolvedConfig) {
+	if !resolved.negative {
+		c.cache.Set(key, resolved)
 	} else {
-		calcConfig, err := c.calculateConfig(domain)
-		if err != nil {
-			return nil, err
+		negativeTtl := time.Duration(serverConfig.Get().Cache.NegativeTtlSeconds) * time.Second
+		if negativeTtl > 0 {
+			c.cache.SetWithTTL(key, resolved, negativeTtl)
 		}
-
-		c.cache.Set(domainPrefix+domain, calcConfig, cache.DefaultExpiration)
-		updateCacheCount()
-		return calcConfig, nil
 	}
+	updateCacheCount()
 }
 
-func (c *configCache) calculateConfig(domain string) (*models.ReactConfig, error) {
+func (c *configCache) calculateConfig(domain string) (*ResolvedConfig, error) {
 	c.log.Info("Calculating the complete config for domain " + domain)
 
-	globs, found := c.cache.Get(globListKey)
-	if !found {
-		var err error
-		globs, err = GetDatabase().ListGlobs(c.ctx)
+	templates := make(map[string]bool)
+	layers, lastModified, err := c.getLayers(domain, templates)
+	if err != nil {
+		return nil, err
+	}
+
+	// Removing a config (or changing a template's matcher) changes the configs of the domains it applied
+	// to, without anything still stored for them changing
+	if len(layers) == 0 {
+		lastModified = 0
+	} else if removalStore, ok := GetStore().(RemovalStore); ok {
+		removedTs, err := removalStore.GetRemovedTs(c.ctx)
 		if err != nil {
 			return nil, err
 		}
+		if removedTs > lastModified {
+			lastModified = removedTs
+		}
+	}
 
-		c.cache.Set(globListKey, globs, cache.DefaultExpiration)
-		updateCacheCount()
+	resolution := models.ResolveLayers(layers)
+	for _, conflict := range resolution.Conflicts {
+		c.log.Warn("Templates " + strings.Join(conflict.Templates, " and ") + " have the same weight and set different values for " + conflict.Key + " - using the value from " + conflict.Winner)
 	}
 
-	type templateTuple struct {
-		template *models.ReactConfig
-		weight   int
+	resolved, err := newResolvedConfig(&resolution.Config, lastModified)
+	if err != nil {
+		return nil, err
 	}
-	templates := make([]templateTuple, 0)
-	for _, template := range globs.([]string) {
-		if !glob.Glob(template, domain) {
+	resolved.templates = templates
+	resolved.negative = len(layers) == 0
+	return resolved, nil
+}
+
+// ExplainConfig calculates the complete config for the domain (bypassing the cache), keeping track
+// of where each value came from.
+func (c *configCache) ExplainConfig(domain string) (*models.Resolution, error) {
+	if models.IsTemplate(domain) {
+		// Templates are used as they are stored
+		layers := make([]*models.ConfigLayer, 0)
+		record, err := GetStore().GetConfig(c.ctx, domain)
+		if err == nil {
+			layers = append(layers, &models.ConfigLayer{Source: domain, IsTemplate: true, Weight: record.Metadata.Weight, Config: record.Config, Merge: record.Metadata.Merge})
+		} else if err != ErrConfigNotFound {
+			return nil, err
+		}
+		return models.ResolveLayers(layers), nil
+	}
+
+	layers, _, err := c.getLayers(domain, nil)
+	if err != nil {
+		return nil, err
+	}
+	return models.ResolveLayers(layers), nil
+}
+
+// getLayers gets the configs which make up the domain's complete config, least important first,
+// along with the most recent time any of them changed. The named templates which a config extends
+// come immediately before it. If templates is not nil, every template looked at is added to it.
+func (c *configCache) getLayers(domain string, templates map[string]bool) ([]*models.ConfigLayer, int64, error) {
+	matchers, err := c.getTemplateMatchers()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	templateLayers := make([]*models.ConfigLayer, 0)
+	extends := make(map[string][]string)
+	lastModified := int64(0)
+	for _, t := range matchers {
+		captures, matched := t.matcher.Match(domain)
+		if !matched {
 			continue
 		}
+		template := t.template
+		addTemplate(templates, template)
 
-		config, err := c.GetConfig(template)
-		if err != nil {
-			return nil, err
+		record, err := c.getRecord(template)
+		if err == ErrConfigNotFound {
+			continue // deleted since we listed the globs
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if record.UpdatedTs > lastModified {
+			lastModified = record.UpdatedTs
 		}
 
-		weight := 0
-		if (*config)["hstoday.weight"] != nil {
-			weight = int((*config)["hstoday.weight"].(float64))
+		if record.Metadata.IsEnabled() {
+			config := c.interpolate(record, domain, captures)
+			templateLayers = append(templateLayers, &models.ConfigLayer{Source: template, IsTemplate: true, Weight: record.Metadata.Weight, Config: config, Merge: record.Metadata.Merge})
+			extends[template] = record.Metadata.Extends
 		}
-		templates = append(templates, templateTuple{config, weight})
 	}
 
 	// Sort the templates so we have the least important first
-	sort.Slice(templates, func(i int, j int) bool {
-		return templates[i].weight < templates[j].weight
-	})
+	models.SortTemplates(templateLayers)
 
-	// Build the default config
-	domainDefaults := &models.ReactConfig{}
-	for _, t := range templates {
-		t.template.ApplyDefaults(domainDefaults)
-		domainDefaults = t.template
+	layers := make([]*models.ConfigLayer, 0, len(templateLayers))
+	for _, layer := range templateLayers {
+		parentLayers, parentsModified, err := c.getParentLayers(extends[layer.Source], domain, map[string]bool{layer.Source: true}, make(map[string]bool), templates)
+		if err != nil {
+			return nil, 0, err
+		}
+		if parentsModified > lastModified {
+			lastModified = parentsModified
+		}
+		layers = append(layers, parentLayers...)
+		layers = append(layers, layer)
 	}
 
-	// Now that we have a default config for the domain, we can apply it to the domain's real config
-	dbConfig, err := GetDatabase().GetConfig(c.ctx, domain)
-	if err == sql.ErrNoRows {
-		dbConfig = models.ReactConfig{}
-	} else if err != nil {
+	// The domain's own config always wins
+	record, err := c.getRecord(domain)
+	if err == nil {
+		if record.UpdatedTs > lastModified {
+			lastModified = record.UpdatedTs
+		}
+		if record.Metadata.IsEnabled() {
+			parentLayers, parentsModified, err := c.getParentLayers(record.Metadata.Extends, domain, map[string]bool{domain: true}, make(map[string]bool), templates)
+			if err != nil {
+				return nil, 0, err
+			}
+			if parentsModified > lastModified {
+				lastModified = parentsModified
+			}
+			layers = append(layers, parentLayers...)
+
+			config := c.interpolate(record, domain, nil)
+			layers = append(layers, &models.ConfigLayer{Source: domain, Config: config, Merge: record.Metadata.Merge})
+		}
+	} else if err != ErrConfigNotFound {
+		return nil, 0, err
+	}
+
+	return layers, lastModified, nil
+}
+
+// getParentLayers gets the layers for the named templates in extends, each preceded by the templates
+// it extends in turn, along with the most recent time any of them changed. Chain holds the configs
+// which led here, to avoid cycles, and added holds the templates which have already been included.
+// If templates is not nil, every template looked at is added to it.
+func (c *configCache) getParentLayers(extends []string, domain string, chain map[string]bool, added map[string]bool, templates map[string]bool) ([]*models.ConfigLayer, int64, error) {
+	layers := make([]*models.ConfigLayer, 0)
+	lastModified := int64(0)
+	for _, parent := range extends {
+		addTemplate(templates, parent)
+		if added[parent] {
+			continue
+		}
+		if chain[parent] {
+			// Cycles are rejected when configs are set, so this should only happen if a config was edited on disk
+			c.log.Warn("Ignoring " + parent + " as extending it creates a cycle")
+			continue
+		}
+
+		record, err := c.getRecord(parent)
+		if err == ErrConfigNotFound {
+			c.log.Warn("Ignoring " + parent + " as it does not exist")
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if record.UpdatedTs > lastModified {
+			lastModified = record.UpdatedTs
+		}
+		if !record.Metadata.IsEnabled() {
+			continue
+		}
+
+		chain[parent] = true
+		parentLayers, parentsModified, err := c.getParentLayers(record.Metadata.Extends, domain, chain, added, templates)
+		delete(chain, parent)
+		if err != nil {
+			return nil, 0, err
+		}
+		if parentsModified > lastModified {
+			lastModified = parentsModified
+		}
+
+		added[parent] = true
+		layers = append(layers, parentLayers...)
+		config := c.interpolate(record, domain, nil)
+		layers = append(layers, &models.ConfigLayer{Source: parent, Weight: record.Metadata.Weight, Config: config, Merge: record.Metadata.Merge})
+	}
+
+	return layers, lastModified, nil
+}
+
+func addTemplate(templates map[string]bool, template string) {
+	if templates != nil {
+		templates[template] = true
+	}
+}
+
+// templateMatcher is a template along with its compiled matcher
+type templateMatcher struct {
+	template string
+	matcher  *models.CompiledMatcher
+}
+
+// getTemplateMatchers gets the matchers for every template. The matchers are cached alongside the
+// configs so that they don't need to be compiled for every domain, and are refreshed whenever a
+// template changes.
+func (c *configCache) getTemplateMatchers() ([]*templateMatcher, error) {
+	if c.pending == nil {
+		cached, found := c.cache.Get(globListKey)
+		if found {
+			return cached.([]*templateMatcher), nil
+		}
+	}
+
+	globs, err := GetStore().ListGlobs(c.ctx)
+	if err != nil {
 		return nil, err
 	}
+	// Globs which are about to be created need to be included too
+	stored := make(map[string]bool)
+	for _, glob := range globs {
+		stored[glob] = true
+	}
+	for domain, record := range c.pending {
+		if record != nil && strings.Contains(domain, "*") && !stored[domain] {
+			globs = append(globs, domain)
+		}
+	}
 
-	dbConfig.ApplyDefaults(domainDefaults)
-	return &dbConfig, nil
+	templates := make([]*templateMatcher, 0, len(globs))
+	for _, template := range globs {
+		if models.IsNamedTemplate(template) {
+			continue // only used through extends
+		}
+
+		record, err := c.getRecord(template)
+		if err == ErrConfigNotFound {
+			continue // deleted since we listed the globs
+		} else if err != nil {
+			return nil, err
+		}
+
+		matcher, err := models.CompileTemplateMatcher(template, record.Metadata.Matcher)
+		if err != nil {
+			// Matchers are checked when they are set, so this should only happen if the config was edited on disk
+			c.log.Error("Ignoring template "+template+" with an invalid matcher: ", err)
+			continue
+		}
+		templates = append(templates, &templateMatcher{template: template, matcher: matcher})
+	}
+
+	if c.pending == nil {
+		c.cache.Set(globListKey, templates)
+		updateCacheCount()
+	}
+	return templates, nil
+}
+
+// getRecord gets the domain's stored config, or its pending change if there is one
+func (c *configCache) getRecord(domain string) (*ConfigRecord, error) {
+	if record, found := c.pending[domain]; found {
+		if record == nil {
+			return nil, ErrConfigNotFound
+		}
+		return record, nil
+	}
+	return GetStore().GetConfig(c.ctx, domain)
 }
 
+// interpolate substitutes variables into the stored config for when it is used for the domain
+func (c *configCache) interpolate(record *ConfigRecord, domain string, captures []string) (models.ReactConfig) {
+	variables := models.NewVariables(serverConfig.Get().Variables, domain, captures)
+	interpolated, unknown := variables.Interpolate(record.Config)
+	if len(unknown) > 0 {
+		// Variables are checked when configs are set, so the server's variables must have changed since
+		c.log.Warn("Config for " + record.Domain + " uses unknown variables: " + strings.Join(unknown, ", "))
+	}
+	return interpolated
+}
+
+// SetConfig replaces the domain's config. Any metadata keys in the config (such as hstoday.weight)
+// are moved into the metadata, and the rest of the metadata is left as it is.
 func (c *configCache) SetConfig(domain string, config *models.ReactConfig) (*models.ReactConfig, error) {
-	var err error
+	action := models.AuditActionSet
+	if config == nil {
+		action = models.AuditActionDelete
+	}
+	return c.replaceConfig(domain, config, action)
+}
+
+// replaceConfig replaces (or deletes, if config is nil) the domain's config, recording the action
+// in the audit log.
+func (c *configCache) replaceConfig(domain string, config *models.ReactConfig, action string) (*models.ReactConfig, error) {
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	record, err := GetStore().GetConfig(c.ctx, domain)
+	if err != nil && err != ErrConfigNotFound {
+		return nil, err
+	}
+
+	if config == nil {
+		return c.setConfig(domain, nil, models.ConfigMetadata{}, record, action)
+	}
+	return c.setConfigWithMetadata(domain, *config, nil, record, action)
+}
+
+// PatchConfig applies the patch to the domain's stored config (not the calculated one). Domains
+// without a config are patched as though they had an empty config.
+func (c *configCache) PatchConfig(domain string, patch *models.ConfigPatch) (*models.ReactConfig, error) {
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	dbConfig := models.ReactConfig{}
+	record, err := GetStore().GetConfig(c.ctx, domain)
+	if err == nil {
+		dbConfig = record.Config
+	} else if err != ErrConfigNotFound {
+		return nil, err
+	}
+
+	newConfig, err := patch.Apply(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Info("Patching config for " + domain)
+	return c.setConfigWithMetadata(domain, newConfig, nil, record, models.AuditActionPatch)
+}
+
+// SetMetadata replaces the metadata for the domain's config, which must already exist
+func (c *configCache) SetMetadata(domain string, metadata models.ConfigMetadata) (*models.ConfigMetadata, error) {
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	record, err := GetStore().GetConfig(c.ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Info("Updating metadata for " + domain)
+	_, err = c.setConfig(domain, &record.Config, metadata, record, models.AuditActionMetadata)
+	if err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// setConfigWithMetadata moves any metadata keys from the config into the metadata before writing it.
+// When metadata is nil, the domain's current metadata (if any) is used as a starting point.
+func (c *configCache) setConfigWithMetadata(domain string, config models.ReactConfig, metadata *models.ConfigMetadata, currentRecord *ConfigRecord, action string) (*models.ReactConfig, error) {
+	newMetadata := models.ConfigMetadata{}
+	if metadata != nil {
+		newMetadata = *metadata
+	} else if currentRecord != nil {
+		newMetadata = currentRecord.Metadata
+	}
+
+	config, _, err := models.ExtractMetadata(config, &newMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.setConfig(domain, &config, newMetadata, currentRecord, action)
+}
+
+// setConfig writes the config, where currentRecord is what is currently stored for the domain (or nil)
+// and action is what the change is recorded as in the audit log.
+func (c *configCache) setConfig(domain string, config *models.ReactConfig, metadata models.ConfigMetadata, currentRecord *ConfigRecord, action string) (*models.ReactConfig, error) {
+	if config != nil {
+		if err := c.validateConfig(domain, *config, metadata); err != nil {
+			return nil, err
+		}
+		if err := models.CheckExtends(domain, metadata.Extends, c.storedExtends); err != nil {
+			return nil, err
+		}
+		if err := c.checkMatcher(domain, metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	expectedVersion, err := c.precondition.check(currentRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	var record *ConfigRecord
+	if config != nil {
+		record = &ConfigRecord{Domain: domain, Config: *config, Metadata: metadata}
+	}
+	err = c.validateAffectedDomains(map[string]*ConfigRecord{domain: record})
+	if err != nil {
+		return nil, err
+	}
+
+	// Changes are never made without being audited
+	auditStore, ok := GetStore().(AuditStore)
+	if !ok {
+		return nil, ErrAuditNotSupported
+	}
+	diff := c.describeChange(domain, currentRecord, config, &metadata)
+	auditEntry := c.newAuditEntry(domain, action, diff)
+
+	notifier := c.newChangeNotifier([]string{domain})
 	if config != nil {
 		c.log.Info("Updating config for " + domain)
-		err = GetDatabase().UpsertConfig(c.ctx, domain, *config)
+		err = auditStore.UpsertAuditedConfig(c.ctx, domain, *config, metadata, expectedVersion, auditEntry)
 	} else {
 		c.log.Info("Deleting config for " + domain)
-		err = GetDatabase().DeleteConfig(c.ctx, domain)
+		err = auditStore.DeleteAuditedConfig(c.ctx, domain, expectedVersion, auditEntry)
+	}
+	if err == ErrVersionMismatch {
+		// Something else changed the config between us reading and writing it
+		return nil, ErrPreconditionFailed
+	} else if err != nil {
+		return nil, err
+	}
+
+	c.recordRevision(domain, config, &metadata)
+	invalidateDomain(domain)
+	c.publishCacheEvent(&cachebus.Event{Domain: domain})
+	notifier.notify(domain, action, diff)
+
+	return c.GetConfig(domain)
+}
+
+// checkMatcher returns a *models.ForbiddenError if the template's matcher could match hosts which the
+// token making the change may not write to. The template's name is checked when the token is.
+func (c *configCache) checkMatcher(domain string, metadata models.ConfigMetadata) (error) {
+	if c.token == nil || !models.IsTemplate(domain) || models.IsNamedTemplate(domain) {
+		return nil
 	}
+	if !c.token.CanUseMatcher(models.PermissionWrite, metadata.Matcher) {
+		return &models.ForbiddenError{Message: "Token cannot write to every host the matcher for " + domain + " matches"}
+	}
+	return nil
+}
+
+// storedExtends looks up the named templates which a stored config extends
+func (c *configCache) storedExtends(name string) ([]string, bool) {
+	record, err := GetStore().GetConfig(c.ctx, name)
 	if err != nil {
+		if err != ErrConfigNotFound {
+			c.log.Error("Error looking up "+name+": ", err)
+		}
+		return nil, false
+	}
+	return record.Metadata.Extends, true
+}
+
+// validateConfig checks that the config and its metadata can be stored for the domain, returning a
+// *models.ValidationError or *models.SchemaValidationError if not. Configs are only checked against a
+// schema when validation is enabled. Templates are checked against the schema for their name, and
+// validateAffectedDomains checks the configs calculated for the domains they apply to.
+func (c *configCache) validateConfig(domain string, config models.ReactConfig, metadata models.ConfigMetadata) (error) {
+	err := checkConfig(domain, config, metadata)
+	if err != nil {
+		return err
+	}
+
+	if !serverConfig.Get().Validation.Enabled {
+		return nil
+	}
+	schemaName := SchemaNameForDomain(domain)
+	if schemaName == "" {
+		return nil
+	}
+	return ValidateAgainstSchema(c.ctx, schemaName, domain, config)
+}
+
+// validateAffectedDomains checks the configs calculated for the domains which the changing templates
+// apply to, as they will be once the changes are made, against each domain's schema. Changes maps each
+// changing config to its new record (or nil if it is being deleted). Only the known domains (those with
+// a stored config or in the cache) are checked, and only when validation is enabled. A
+// *models.SchemaValidationError is returned for the first domain which would not match its schema.
+func (c *configCache) validateAffectedDomains(changes map[string]*ConfigRecord) (error) {
+	if !serverConfig.Get().Validation.Enabled {
+		return nil
+	}
+
+	changing := make([]string, 0, len(changes))
+	templates := make([]string, 0)
+	for domain := range changes {
+		changing = append(changing, domain)
+		if models.IsTemplate(domain) {
+			templates = append(templates, domain)
+		}
+	}
+	if len(templates) == 0 {
+		return nil
+	}
+	sort.Strings(templates)
+
+	tracker := c.trackChanges(changing)
+	tracker.assumeChanges(changes)
+	affected := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, template := range templates {
+		for _, domain := range tracker.affectedDomains(template) {
+			if !seen[domain] {
+				seen[domain] = true
+				affected = append(affected, domain)
+			}
+		}
+	}
+
+	pending := *c
+	pending.pending = changes
+	for _, domain := range affected {
+		schemaName := SchemaNameForDomain(domain)
+		if schemaName == "" {
+			continue
+		}
+
+		layers, _, err := pending.getLayers(domain, nil)
+		if err != nil {
+			return err
+		}
+		err = ValidateAgainstSchema(c.ctx, schemaName, domain, models.ResolveLayers(layers).Config)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkConfig checks that the config and its metadata can be stored for the domain, returning a
+// *models.ValidationError if not.
+func checkConfig(domain string, config models.ReactConfig, metadata models.ConfigMetadata) (error) {
+	err := config.Validate()
+	if err != nil {
+		return err
+	}
+	err = metadata.Validate()
+	if err != nil {
+		return err
+	}
+
+	if models.IsNamedTemplate(domain) && (strings.Contains(domain, "*") || domain == models.NamedTemplatePrefix) {
+		return &models.ValidationError{Message: "Named templates must have a name, and cannot contain wildcards"}
+	}
+
+	captures := 0
+	if strings.Contains(domain, "*") {
+		matcher, err := models.CompileTemplateMatcher(domain, metadata.Matcher)
+		if err != nil {
+			return err
+		}
+		captures = matcher.Captures()
+	} else if metadata.Matcher != nil {
+		return &models.ValidationError{Message: "Only templates can have a matcher"}
+	}
+
+	return models.ValidateVariables(config, captures, serverConfig.Get().Variables)
+}
+
+// ValidateConfig checks whether SetConfig would accept the config for the domain without storing it.
+// The config is checked against the named schema (if given) even when validation is not enabled.
+func (c *configCache) ValidateConfig(domain string, config models.ReactConfig, schemaName string) (*models.ValidationResult, error) {
+	record, err := GetStore().GetConfig(c.ctx, domain)
+	if err != nil && err != ErrConfigNotFound {
+		return nil, err
+	}
+
+	metadata := models.ConfigMetadata{}
+	if record != nil {
+		metadata = record.Metadata
+	}
+	config, _, err = models.ExtractMetadata(config, &metadata)
+	if err == nil {
+		err = checkConfig(domain, config, metadata)
+	}
+	if err == nil {
+		err = models.CheckExtends(domain, metadata.Extends, c.storedExtends)
+	}
+	if err == nil && schemaName != "" {
+		err = ValidateAgainstSchema(c.ctx, schemaName, domain, config)
+	}
+	return newValidationResult(schemaName, err)
+}
+
+// ValidateResolvedConfig checks the complete config for the domain against the named schema
+func (c *configCache) ValidateResolvedConfig(domain string, schemaName string) (*models.ValidationResult, error) {
+	config, err := c.GetConfig(domain)
+	if err != nil {
+		return nil, err
+	}
+	return newValidationResult(schemaName, ValidateAgainstSchema(c.ctx, schemaName, domain, *config))
+}
+
+// newValidationResult describes the outcome of validating a config. Errors which aren't about the
+// config being invalid are returned as they are.
+func newValidationResult(schemaName string, err error) (*models.ValidationResult, error) {
+	result := &models.ValidationResult{Valid: err == nil, Schema: schemaName, Errors: make([]*models.SchemaError, 0)}
+	if schemaErr, ok := err.(*models.SchemaValidationError); ok {
+		result.Errors = schemaErr.Errors
+	} else if validationErr, ok := err.(*models.ValidationError); ok {
+		result.Errors = append(result.Errors, &models.SchemaError{Path: "", Message: validationErr.Message})
+	} else if err != nil {
 		return nil, err
 	}
+	return result, nil
+}
+
+// invalidateDomain removes any cached state affected by a change to the given domain's stored config.
+// When the domain is a template, only the configs which used it (or which a glob now matches) are
+// removed, so that busy servers don't have to calculate every config again.
+func invalidateDomain(domain string) {
+	baseCache := getBaseCache().cache
+	defer updateCacheCount()
 
 	// Purge the domain from the cache
-	c.cache.Delete(domainPrefix + domain)
-	updateCacheCount()
+	if !models.IsTemplate(domain) {
+		if baseCache.Delete(domainPrefix + domain) {
+			metrics.IncCacheInvalidation(metricsCacheName, metrics.InvalidationDomain, 1)
+		}
+		return
+	}
+	if baseCache.Delete(templatePrefix + domain) {
+		metrics.IncCacheInvalidation(metricsCacheName, metrics.InvalidationDomain, 1)
+	}
 
-	// If the domain is a glob, clear the cache entirely. It is relatively rare that people will be
-	// setting glob configs, so this shouldn't be that bad. It also means we don't need to track which
-	// globs were used where.
-	if strings.Contains(domain, "*") {
-		for k := range c.cache.Items() {
-			c.cache.Delete(k)
+	// Named templates are only used through extends, so can't start applying to a domain by themselves
+	var matcher *models.CompiledMatcher
+	if !models.IsNamedTemplate(domain) {
+		var err error
+		matcher, err = refreshTemplateMatcher(domain)
+		if err != nil {
+			logrus.Error("Failed to refresh the matcher for "+domain+", clearing the cache: ", err)
+			flushCache()
+			return
 		}
-		updateCacheCount()
 	}
 
-	return c.GetConfig(domain)
+	evicted := 0
+	for key, item := range baseCache.Items() {
+		if !strings.HasPrefix(key, domainPrefix) {
+			continue
+		}
+
+		resolved := item.(*ResolvedConfig)
+		matched := false
+		if matcher != nil {
+			_, matched = matcher.Match(strings.TrimPrefix(key, domainPrefix))
+		}
+		if resolved.templates[domain] || matched {
+			baseCache.Delete(key)
+			evicted++
+		}
+	}
+	metrics.IncCacheInvalidation(metricsCacheName, metrics.InvalidationTemplate, evicted)
+}
+
+// refreshTemplateMatcher replaces the glob's matcher in the cached list of matchers (if there is one)
+// with its current matcher, returning the new matcher or nil if the glob no longer exists.
+func refreshTemplateMatcher(template string) (*models.CompiledMatcher, error) {
+	var matcher *models.CompiledMatcher
+	record, err := GetStore().GetConfig(context.Background(), template)
+	if err == nil {
+		matcher, err = models.CompileTemplateMatcher(template, record.Metadata.Matcher)
+		if err != nil {
+			// Matchers are checked when they are set, so this should only happen if the config was edited on disk
+			logrus.Error("Ignoring template "+template+" with an invalid matcher: ", err)
+			matcher = nil
+		}
+	} else if err != ErrConfigNotFound {
+		return nil, err
+	}
+
+	baseCache := getBaseCache().cache
+	cached, found := baseCache.Get(globListKey)
+	if !found {
+		return matcher, nil
+	}
+
+	oldTemplates := cached.([]*templateMatcher)
+	templates := make([]*templateMatcher, 0, len(oldTemplates)+1)
+	for _, t := range oldTemplates {
+		if t.template != template {
+			templates = append(templates, t)
+		}
+	}
+	if matcher != nil {
+		templates = append(templates, &templateMatcher{template: template, matcher: matcher})
+	}
+	baseCache.Set(globListKey, templates)
+	return matcher, nil
+}
+
+// flushCache removes everything from the cache
+func flushCache() {
+	baseCache := getBaseCache().cache
+	evicted := 0
+	for k := range baseCache.Items() {
+		baseCache.Delete(k)
+		if strings.HasPrefix(k, domainPrefix) || strings.HasPrefix(k, templatePrefix) {
+			evicted++
+		}
+	}
+	metrics.IncCacheInvalidation(metricsCacheName, metrics.InvalidationFlush, evicted)
+	updateCacheCount()
 }
 
 func (c *configCache) DeleteConfig(domain string) (*models.ReactConfig, error) {
 	return c.SetConfig(domain, nil)
 }
+
+// ListConfigs summarizes the stored configs which match the filter, ordered by domain
+func (c *configCache) ListConfigs(filter *models.ConfigFilter) ([]*models.ConfigSummary, error) {
+	records, err := GetStore().ListConfigs(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*models.ConfigSummary, 0)
+	for _, record := range records {
+		if !filter.Matches(record.Domain, record.Config) {
+			continue
+		}
+		metadata := record.Metadata
+		results = append(results, &models.ConfigSummary{
+			Domain:          record.Domain,
+			IsGlob:          strings.Contains(record.Domain, "*") && !models.IsNamedTemplate(record.Domain),
+			IsNamedTemplate: models.IsNamedTemplate(record.Domain),
+			UpdatedTs:       record.UpdatedTs,
+			Metadata:        &metadata,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Domain < results[j].Domain
+	})
+	return results, nil
+}
+
+// ExportConfigs gets every stored config, including templates, without applying any templates
+func (c *configCache) ExportConfigs() (*models.ConfigExport, error) {
+	records, err := GetStore().ListConfigs(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.ConfigExport{
+		ExportedTs: util.NowMillis(),
+		Configs:    make([]*models.ExportedConfig, 0, len(records)),
+	}
+	for _, record := range records {
+		metadata := record.Metadata
+		export.Configs = append(export.Configs, &models.ExportedConfig{Domain: record.Domain, Config: record.Config, Metadata: &metadata})
+	}
+	return export, nil
+}
+
+// ImportConfigs stores all of the given configs at once. In replace mode, stored configs which are not
+// being imported are deleted. When dryRun is set, the changes which would be made are returned
+// without making them.
+func (c *configCache) ImportConfigs(configs []*models.ExportedConfig, mode string, dryRun bool) (*models.ImportResult, error) {
+	if _, ok := GetStore().(ImportStore); !ok && !dryRun {
+		return nil, ErrImportNotSupported
+	}
+
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	existing, err := GetStore().ListConfigs(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	existingRecords := make(map[string]*ConfigRecord)
+	for _, record := range existing {
+		existingRecords[record.Domain] = record
+	}
+
+	result := &models.ImportResult{
+		Mode:    mode,
+		DryRun:  dryRun,
+		Created: make([]string, 0),
+		Updated: make([]string, 0),
+		Deleted: make([]string, 0),
+	}
+	upserts := make([]*ConfigRecord, 0)
+	imported := make(map[string]bool)
+
+	// What every config will extend once the import is done, for checking the imported configs
+	extends := make(map[string][]string)
+	if mode != models.ImportModeReplace {
+		for _, record := range existing {
+			extends[record.Domain] = record.Metadata.Extends
+		}
+	}
+
+	for _, config := range configs {
+		imported[config.Domain] = true
+
+		// Older exports have the metadata in the config itself
+		metadata := models.ConfigMetadata{}
+		if config.Metadata != nil {
+			metadata = *config.Metadata
+		}
+		newConfig, _, err := models.ExtractMetadata(config.Config, &metadata)
+		if err == nil {
+			err = c.validateConfig(config.Domain, newConfig, metadata)
+		}
+		if err == nil {
+			err = c.checkMatcher(config.Domain, metadata)
+		}
+		if _, ok := err.(*models.SchemaValidationError); ok {
+			// These already say which config is invalid
+			return nil, err
+		} else if _, ok := err.(*models.ForbiddenError); ok {
+			return nil, err
+		} else if err != nil {
+			return nil, &models.ValidationError{Message: config.Domain + ": " + err.Error()}
+		}
+		extends[config.Domain] = metadata.Extends
+
+		current, found := existingRecords[config.Domain]
+		if !found {
+			result.Created = append(result.Created, config.Domain)
+		} else if !reflect.DeepEqual(normalizeConfig(current.Config), normalizeConfig(newConfig)) || !reflect.DeepEqual(current.Metadata, metadata) {
+			result.Updated = append(result.Updated, config.Domain)
+		} else {
+			result.Unchanged++
+			continue
+		}
+		upserts = append(upserts, &ConfigRecord{Domain: config.Domain, Config: newConfig, Metadata: metadata})
+	}
+	if mode == models.ImportModeReplace {
+		for _, record := range existing {
+			if !imported[record.Domain] {
+				result.Deleted = append(result.Deleted, record.Domain)
+			}
+		}
+	}
+
+	lookup := func(name string) ([]string, bool) {
+		parents, found := extends[name]
+		return parents, found
+	}
+	for _, config := range configs {
+		err := models.CheckExtends(config.Domain, extends[config.Domain], lookup)
+		if err != nil {
+			return nil, &models.ValidationError{Message: config.Domain + ": " + err.Error()}
+		}
+	}
+
+	changes := make(map[string]*ConfigRecord)
+	for _, record := range upserts {
+		changes[record.Domain] = record
+	}
+	for _, domain := range result.Deleted {
+		changes[domain] = nil
+	}
+	err = c.validateAffectedDomains(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun || !result.HasChanges() {
+		return result, nil
+	}
+
+	changed := append(append([]string{}, result.Created...), result.Updated...)
+	changed = append(changed, result.Deleted...)
+
+	// Changes are never made without being audited
+	auditStore, ok := GetStore().(AuditStore)
+	if !ok {
+		return nil, ErrAuditNotSupported
+	}
+	diffs := make(map[string][]*models.AuditChange)
+	auditEntries := make([]*models.AuditEntry, 0)
+	for _, record := range upserts {
+		config := record.Config
+		metadata := record.Metadata
+		diffs[record.Domain] = c.describeChange(record.Domain, existingRecords[record.Domain], &config, &metadata)
+	}
+	for _, domain := range result.Deleted {
+		diffs[domain] = c.describeChange(domain, existingRecords[domain], nil, nil)
+	}
+	for _, domain := range changed {
+		auditEntries = append(auditEntries, c.newAuditEntry(domain, models.AuditActionImport, diffs[domain]))
+	}
+
+	notifier := c.newChangeNotifier(changed)
+
+	c.log.Info("Importing configs (" + mode + "): " + strconv.Itoa(len(result.Created)) + " created, " + strconv.Itoa(len(result.Updated)) + " updated, " + strconv.Itoa(len(result.Deleted)) + " deleted")
+	err = auditStore.ImportAuditedConfigs(c.ctx, upserts, result.Deleted, auditEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	// Imports tend to touch templates, so it's simplest to start over
+	flushCache()
+	c.publishCacheEvent(&cachebus.Event{Flush: true})
+
+	for _, record := range upserts {
+		config := record.Config
+		metadata := record.Metadata
+		c.recordRevision(record.Domain, &config, &metadata)
+		notifier.notify(record.Domain, models.AuditActionImport, diffs[record.Domain])
+	}
+	for _, domain := range result.Deleted {
+		c.recordRevision(domain, nil, nil)
+		notifier.notify(domain, models.AuditActionImport, diffs[domain])
+	}
+
+	return result, nil
+}
+
+// normalizeConfig round trips the config through JSON so that configs can be compared regardless of
+// where they came from.
+func normalizeConfig(config models.ReactConfig) (interface{}) {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return config
+	}
+	var normalized interface{}
+	if err = json.Unmarshal(b, &normalized); err != nil {
+		return config
+	}
+	return normalized
+}
+
+func (c *configCache) recordRevision(domain string, config *models.ReactConfig, metadata *models.ConfigMetadata) {
+	revisionStore, ok := GetStore().(RevisionStore)
+	if !ok {
+		return
+	}
+
+	revision := &models.ConfigRevision{
+		Domain:    domain,
+		CreatedTs: util.NowMillis(),
+		Actor:     c.actor,
+		Action:    models.RevisionActionSet,
+	}
+	if config != nil {
+		revision.Config = *config
+		revision.Metadata = metadata
+	} else {
+		revision.Action = models.RevisionActionDelete
+	}
+
+	// The config has already been changed at this point, so there's little value in failing the whole request
+	err := revisionStore.InsertRevision(c.ctx, revision)
+	if err != nil {
+		c.log.Error("Failed to record revision for "+domain+": ", err)
+	}
+}
+
+// describeChange gets the differences made by a change to the domain's config, where before is what
+// was stored for the domain before the change (or nil) and config is the new config (or nil if it
+// was deleted).
+func (c *configCache) describeChange(domain string, before *ConfigRecord, config *models.ReactConfig, metadata *models.ConfigMetadata) ([]*models.AuditChange) {
+	var beforeConfig models.ReactConfig
+	var beforeMetadata *models.ConfigMetadata
+	if before != nil {
+		beforeConfig = before.Config
+		beforeMetadata = &before.Metadata
+	}
+	var afterConfig models.ReactConfig
+	if config != nil {
+		afterConfig = *config
+	}
+
+	diff, err := models.DiffStoredConfigs(beforeConfig, beforeMetadata, afterConfig, metadata)
+	if err != nil {
+		c.log.Error("Failed to describe changes to "+domain+": ", err)
+		return make([]*models.AuditChange, 0)
+	}
+	return diff
+}
+
+// newAuditEntry describes a change which is about to be made for the audit log. The store records
+// the entry along with the change.
+func (c *configCache) newAuditEntry(domain string, action string, diff []*models.AuditChange) (*models.AuditEntry) {
+	return &models.AuditEntry{
+		CreatedTs:  util.NowMillis(),
+		Actor:      c.actor,
+		RemoteAddr: c.remoteAddr,
+		Domain:     domain,
+		Action:     action,
+		Diff:       diff,
+	}
+}
+
+// ListAuditEntries gets the audit entries which match the filter, newest first
+func (c *configCache) ListAuditEntries(filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	auditStore, ok := GetStore().(AuditStore)
+	if !ok {
+		return nil, ErrAuditNotSupported
+	}
+
+	return auditStore.ListAuditEntries(c.ctx, filter)
+}
+
+func (c *configCache) ListRevisions(domain string) ([]*models.ConfigRevision, error) {
+	revisionStore, ok := GetStore().(RevisionStore)
+	if !ok {
+		return nil, ErrRevisionsNotSupported
+	}
+
+	return revisionStore.ListRevisions(c.ctx, domain)
+}
+
+func (c *configCache) GetRevision(domain string, revisionNumber int) (*models.ConfigRevision, error) {
+	revisionStore, ok := GetStore().(RevisionStore)
+	if !ok {
+		return nil, ErrRevisionsNotSupported
+	}
+
+	return revisionStore.GetRevision(c.ctx, domain, revisionNumber)
+}
+
+// RollbackConfig restores the domain's config to how it was at the given revision. The rollback is
+// itself recorded as a new revision.
+func (c *configCache) RollbackConfig(domain string, revisionNumber int) (*models.ReactConfig, error) {
+	revision, err := c.GetRevision(domain, revisionNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Info("Rolling back config for " + domain + " to revision " + strconv.Itoa(revisionNumber))
+	if revision.Action == models.RevisionActionDelete {
+		return c.replaceConfig(domain, nil, models.AuditActionRollback)
+	}
+
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	record, err := GetStore().GetConfig(c.ctx, domain)
+	if err != nil && err != ErrConfigNotFound {
+		return nil, err
+	}
+
+	config := revision.Config
+	if config == nil {
+		config = models.ReactConfig{}
+	}
+
+	// Revisions from before metadata was stored separately have it in the config instead, so those
+	// start from an empty set of metadata.
+	metadata := revision.Metadata
+	if metadata == nil {
+		metadata = &models.ConfigMetadata{}
+	}
+	return c.setConfigWithMetadata(domain, config, metadata, record, models.AuditActionRollback)
+}
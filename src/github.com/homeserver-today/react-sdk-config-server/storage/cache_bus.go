@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"github.com/homeserver-today/react-sdk-config-server/cachebus"
+	"github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Until a bus is opened, changes are only applied to this server's cache
+var cacheBus = cachebus.NewLocalBus()
+
+// OpenCacheBus connects to the bus which keeps the caches of servers sharing the same storage in sync,
+// and starts applying the changes made by other servers to this server's cache. Unless a bus is
+// configured, the Postgres bus is used with the Postgres storage backend. Configs stored in Postgres
+// publish their own changes, so that the events are sent if (and only if) the change is committed.
+func OpenCacheBus() (error) {
+	dbConfig := config.Get().Database
+	storedInPostgres := dbConfig.Driver == config.DriverPostgres || dbConfig.Driver == ""
+	busType := config.Get().Cache.Bus
+	if busType == "" {
+		busType = config.BusNone
+		if storedInPostgres {
+			busType = config.BusPostgres
+		}
+	}
+
+	var bus cachebus.Bus
+	var err error
+	switch busType {
+	case config.BusNone:
+		return nil
+	case config.BusPostgres:
+		bus, err = cachebus.NewPostgresBus(dbConfig.Postgres, config.Get().Cache.Channel, storedInPostgres)
+		break
+	default:
+		return errors.New("unknown cache bus: " + busType)
+	}
+	if err != nil {
+		return err
+	}
+
+	bus.Subscribe(applyCacheEvent)
+	cacheBus = bus
+	return nil
+}
+
+// applyCacheEvent invalidates whatever another server has told us is out of date
+func applyCacheEvent(event *cachebus.Event) {
+	if event.Flush {
+		logrus.Info("Clearing the cache as requested by another server")
+		flushCache()
+	} else {
+		logrus.Info("Invalidating " + event.Domain + " as it was changed by another server")
+		invalidateDomain(event.Domain)
+	}
+}
+
+// publishCacheEvent tells other servers about a change which has already been applied to this
+// server's cache, unless the storage backend publishes its own changes. Failures are only logged as
+// the change has already been made.
+func (c *configCache) publishCacheEvent(event *cachebus.Event) {
+	err := cacheBus.Publish(event)
+	if err != nil {
+		c.log.Error("Failed to tell other servers about the change: ", err)
+	}
+}
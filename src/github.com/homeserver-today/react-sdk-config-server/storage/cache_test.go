@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/sirupsen/logrus"
+)
+
+func TestValidateAffectedDomains(t *testing.T) {
+	store := NewMemoryStore()
+	storeInstance = store
+	ctx := context.Background()
+
+	err := store.UpsertSchema(ctx, &models.StoredSchema{Name: "branded", Schema: []byte(`{"type": "object", "required": ["brand"]}`)})
+	if err != nil {
+		t.Fatalf("failed to store schema: %v", err)
+	}
+	stored := []*ConfigRecord{
+		{Domain: "*.t2bot.io", Config: models.ReactConfig{"brand": "Riot"}},
+		{Domain: "chat.t2bot.io", Config: models.ReactConfig{}},
+		{Domain: "@base", Config: models.ReactConfig{"brand": "Base"}},
+		{Domain: "other.org", Config: models.ReactConfig{}, Metadata: models.ConfigMetadata{Extends: []string{"@base"}}},
+	}
+	for _, record := range stored {
+		err = store.UpsertConfig(ctx, record.Domain, record.Config, record.Metadata, AnyVersion)
+		if err != nil {
+			t.Fatalf("failed to store %s: %v", record.Domain, err)
+		}
+	}
+
+	branded := "validation:\n  enabled: true\n  domains:\n    - domain: chat.t2bot.io\n      schema: branded\n    - domain: other.org\n      schema: branded\n"
+	exact := &models.HostMatcher{Type: models.MatcherExact, Patterns: []string{"elsewhere.t2bot.io"}}
+	tests := []struct {
+		name          string
+		config        string
+		changes       map[string]*ConfigRecord
+		invalidDomain string
+	}{
+		{"changing a template", branded, map[string]*ConfigRecord{"*.t2bot.io": {Domain: "*.t2bot.io", Config: models.ReactConfig{"brand": "Element"}}}, ""},
+		{"deleting a template", branded, map[string]*ConfigRecord{"*.t2bot.io": nil}, "chat.t2bot.io"},
+		{"removing a value", branded, map[string]*ConfigRecord{"*.t2bot.io": {Domain: "*.t2bot.io", Config: models.ReactConfig{}}}, "chat.t2bot.io"},
+		{"no longer matching", branded, map[string]*ConfigRecord{"*.t2bot.io": {Domain: "*.t2bot.io", Config: models.ReactConfig{"brand": "Riot"}, Metadata: models.ConfigMetadata{Matcher: exact}}}, "chat.t2bot.io"},
+		{"deleting an extended template", branded, map[string]*ConfigRecord{"@base": nil}, "other.org"},
+		{"replacing a template", branded, map[string]*ConfigRecord{"*.t2bot.io": nil, "*.io": {Domain: "*.io", Config: models.ReactConfig{"brand": "Riot"}}}, ""},
+		{"changing a domain", branded, map[string]*ConfigRecord{"chat.t2bot.io": nil}, ""},
+		{"validation disabled", "validation:\n  enabled: false\n", map[string]*ConfigRecord{"*.t2bot.io": nil}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTestConfig(t, test.config)
+			c := &configCache{cache: newLruCache(time.Hour, 0, true), ctx: ctx, log: logrus.WithFields(logrus.Fields{})}
+
+			err := c.validateAffectedDomains(test.changes)
+			if test.invalidDomain == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if test.invalidDomain != "" {
+				schemaErr, ok := err.(*models.SchemaValidationError)
+				if !ok {
+					t.Fatalf("expected a *models.SchemaValidationError, got %v", err)
+				}
+				if schemaErr.Domain != test.invalidDomain {
+					t.Errorf("expected %s to be invalid, got %s", test.invalidDomain, schemaErr.Domain)
+				}
+			}
+
+			// Nothing is changed by checking
+			if record, err := store.GetConfig(ctx, "*.t2bot.io"); err != nil || record.Config["brand"] != "Riot" {
+				t.Errorf("expected the stored template to be unchanged, got %v", err)
+			}
+		})
+	}
+}
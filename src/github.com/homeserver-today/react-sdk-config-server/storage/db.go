@@ -3,125 +3,449 @@ package storage
 import (
 	"database/sql"
 
-	_ "github.com/lib/pq" // postgres driver
-	"sync"
+	"github.com/lib/pq"           // postgres driver
+	"github.com/mattn/go-sqlite3" // sqlite driver
+	"path"
 	"github.com/homeserver-today/react-sdk-config-server/config"
 	"github.com/sirupsen/logrus"
 	"github.com/DavidHuie/gomigrate"
 	"context"
 	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/util"
 	"encoding/json"
 )
 
-const selectConfig = "SELECT config FROM configs WHERE hostname = $1;"
-const upsertConfig = "INSERT INTO configs (hostname, config) VALUES ($1, $2) ON CONFLICT (hostname) DO UPDATE SET config = $2;"
+// These statements are written to be understood by both Postgres and SQLite. SQLite numbers parameters
+// in the order they first appear, so they must always be used in ascending order.
+const selectConfig = "SELECT config, metadata, version, updated_ts FROM configs WHERE hostname = $1;"
+const upsertConfig = "INSERT INTO configs (hostname, config, metadata, version, updated_ts) VALUES ($1, $2, $3, 1, $4) ON CONFLICT (hostname) DO UPDATE SET config = $2, metadata = $3, version = configs.version + 1, updated_ts = $4;"
+const insertConfig = "INSERT INTO configs (hostname, config, metadata, version, updated_ts) VALUES ($1, $2, $3, 1, $4) ON CONFLICT (hostname) DO NOTHING;"
+const updateConfig = "UPDATE configs SET config = $1, metadata = $2, updated_ts = $3, version = version + 1 WHERE hostname = $4 AND version = $5;"
 const deleteConfig = "DELETE FROM configs WHERE hostname = $1;"
+const deleteConfigVersion = "DELETE FROM configs WHERE hostname = $1 AND version = $2;"
 const selectGlobs = "SELECT hostname FROM configs WHERE hostname LIKE '%*%';"
+const selectConfigs = "SELECT hostname, config, metadata, version, updated_ts FROM configs ORDER BY hostname;"
+const selectLatestRevision = "SELECT COALESCE(MAX(revision), 0) FROM config_revisions WHERE hostname = $1;"
+const insertRevision = "INSERT INTO config_revisions (hostname, revision, created_ts, actor, action, config, metadata) VALUES ($1, $2, $3, $4, $5, $6, $7);"
+const selectRevisions = "SELECT hostname, revision, created_ts, actor, action FROM config_revisions WHERE hostname = $1 ORDER BY revision DESC;"
+const selectRevision = "SELECT hostname, revision, created_ts, actor, action, config, metadata FROM config_revisions WHERE hostname = $1 AND revision = $2;"
+const insertToken = "INSERT INTO api_tokens (id, name, salt, hash, domains, permissions, created_ts, expires_ts, revoked) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);"
+const selectTokenById = "SELECT id, name, salt, hash, domains, permissions, created_ts, expires_ts, revoked FROM api_tokens WHERE id = $1;"
+const selectTokenByName = "SELECT id, name, salt, hash, domains, permissions, created_ts, expires_ts, revoked FROM api_tokens WHERE name = $1;"
+const selectTokens = "SELECT id, name, salt, hash, domains, permissions, created_ts, expires_ts, revoked FROM api_tokens ORDER BY created_ts;"
+const updateToken = "UPDATE api_tokens SET domains = $1, permissions = $2, expires_ts = $3, revoked = $4 WHERE id = $5;"
+const upsertSchema = "INSERT INTO config_schemas (name, schema, updated_ts) VALUES ($1, $2, $3) ON CONFLICT (name) DO UPDATE SET schema = $2, updated_ts = $3;"
+const selectSchema = "SELECT name, schema, updated_ts FROM config_schemas WHERE name = $1;"
+const selectSchemas = "SELECT name, schema, updated_ts FROM config_schemas ORDER BY name;"
+const deleteSchema = "DELETE FROM config_schemas WHERE name = $1;"
+const upsertState = "INSERT INTO store_state (name, value) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET value = $2;"
+const selectState = "SELECT value FROM store_state WHERE name = $1;"
+const insertAuditEntry = "INSERT INTO audit_log (created_ts, actor, remote_addr, hostname, action, diff) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;"
+// Postgres guesses the type of a parameter from where it is first used, so the casts stop the timestamps
+// being treated as 32 bit integers.
+const selectAuditEntries = "SELECT id, created_ts, actor, remote_addr, hostname, action, diff FROM audit_log WHERE ($1 = '' OR hostname = $1) AND ($2 = '' OR actor = $2) AND created_ts >= $3 AND (CAST($4 AS BIGINT) = 0 OR created_ts <= $4) AND (CAST($5 AS BIGINT) = 0 OR id < $5) ORDER BY id DESC LIMIT $6;"
+
+// Statements which are different for SQLite
+const sqliteInsertAuditEntry = "INSERT INTO audit_log (created_ts, actor, remote_addr, hostname, action, diff) VALUES ($1, $2, $3, $4, $5, $6);"
 
 type Database struct {
 	db         *sql.DB
+	driver     string
 	statements statements
 }
 
 type statements struct {
-	selectConfig *sql.Stmt
-	upsertConfig *sql.Stmt
-	deleteConfig *sql.Stmt
-	selectGlobs  *sql.Stmt
-}
+	selectConfig        *sql.Stmt
+	upsertConfig        *sql.Stmt
+	insertConfig        *sql.Stmt
+	updateConfig        *sql.Stmt
+	deleteConfig        *sql.Stmt
+	deleteConfigVersion *sql.Stmt
+	selectGlobs         *sql.Stmt
+	selectConfigs       *sql.Stmt
 
-var dbInstance *Database
-var singletonDbLock = &sync.Once{}
+	selectLatestRevision *sql.Stmt
+	insertRevision       *sql.Stmt
+	selectRevisions      *sql.Stmt
+	selectRevision       *sql.Stmt
 
-func GetDatabase() (*Database) {
-	if dbInstance == nil {
-		singletonDbLock.Do(func() {
-			err := OpenDatabase(config.Get().Database.Postgres)
-			if err != nil {
-				panic(err)
-			}
-		})
-	}
-	return dbInstance
+	insertToken       *sql.Stmt
+	selectTokenById   *sql.Stmt
+	selectTokenByName *sql.Stmt
+	selectTokens      *sql.Stmt
+	updateToken       *sql.Stmt
+
+	upsertSchema  *sql.Stmt
+	selectSchema  *sql.Stmt
+	selectSchemas *sql.Stmt
+	deleteSchema  *sql.Stmt
+
+	insertAuditEntry   *sql.Stmt
+	selectAuditEntries *sql.Stmt
+
+	upsertState *sql.Stmt
+	selectState *sql.Stmt
 }
 
-func OpenDatabase(connectionString string) (error) {
-	d := &Database{}
+func OpenDatabase(driver string, connectionString string) (*Database, error) {
+	d := &Database{driver: driver}
 	var err error
 
-	if d.db, err = sql.Open("postgres", connectionString); err != nil {
-		return err
+	if d.db, err = sql.Open(driver, connectionString); err != nil {
+		return nil, err
+	}
+
+	var dialect gomigrate.Migratable = gomigrate.Postgres{}
+	migrationsPath := config.Runtime.MigrationsPath
+	if driver == config.DriverSqlite {
+		// SQLite only supports a single writer, and has its own set of migrations
+		d.db.SetMaxOpenConns(1)
+		dialect = gomigrate.Sqlite3{}
+		migrationsPath = path.Join(migrationsPath, "sqlite")
 	}
 
 	// Make sure the database is how we want it
-	migrator, err := gomigrate.NewMigratorWithLogger(d.db, gomigrate.Postgres{}, config.Runtime.MigrationsPath, logrus.StandardLogger())
+	migrator, err := gomigrate.NewMigratorWithLogger(d.db, dialect, migrationsPath, logrus.StandardLogger())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	err = migrator.Migrate()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Prepare the general statements
 	if d.statements.selectConfig, err = d.db.Prepare(selectConfig); err != nil {
-		return err
+		return nil, err
 	}
 	if d.statements.upsertConfig, err = d.db.Prepare(upsertConfig); err != nil {
-		return err
+		return nil, err
+	}
+	if d.statements.insertConfig, err = d.db.Prepare(insertConfig); err != nil {
+		return nil, err
+	}
+	if d.statements.updateConfig, err = d.db.Prepare(updateConfig); err != nil {
+		return nil, err
 	}
 	if d.statements.deleteConfig, err = d.db.Prepare(deleteConfig); err != nil {
-		return err
+		return nil, err
+	}
+	if d.statements.deleteConfigVersion, err = d.db.Prepare(deleteConfigVersion); err != nil {
+		return nil, err
 	}
 	if d.statements.selectGlobs, err = d.db.Prepare(selectGlobs); err != nil {
-		return err
+		return nil, err
+	}
+	if d.statements.selectConfigs, err = d.db.Prepare(selectConfigs); err != nil {
+		return nil, err
+	}
+	if d.statements.selectLatestRevision, err = d.db.Prepare(selectLatestRevision); err != nil {
+		return nil, err
+	}
+	if d.statements.insertRevision, err = d.db.Prepare(insertRevision); err != nil {
+		return nil, err
+	}
+	if d.statements.selectRevisions, err = d.db.Prepare(selectRevisions); err != nil {
+		return nil, err
+	}
+	if d.statements.selectRevision, err = d.db.Prepare(selectRevision); err != nil {
+		return nil, err
+	}
+	if d.statements.insertToken, err = d.db.Prepare(insertToken); err != nil {
+		return nil, err
+	}
+	if d.statements.selectTokenById, err = d.db.Prepare(selectTokenById); err != nil {
+		return nil, err
+	}
+	if d.statements.selectTokenByName, err = d.db.Prepare(selectTokenByName); err != nil {
+		return nil, err
+	}
+	if d.statements.selectTokens, err = d.db.Prepare(selectTokens); err != nil {
+		return nil, err
+	}
+	if d.statements.updateToken, err = d.db.Prepare(updateToken); err != nil {
+		return nil, err
+	}
+	if d.statements.upsertSchema, err = d.db.Prepare(upsertSchema); err != nil {
+		return nil, err
+	}
+	if d.statements.selectSchema, err = d.db.Prepare(selectSchema); err != nil {
+		return nil, err
+	}
+	if d.statements.selectSchemas, err = d.db.Prepare(selectSchemas); err != nil {
+		return nil, err
+	}
+	if d.statements.deleteSchema, err = d.db.Prepare(deleteSchema); err != nil {
+		return nil, err
+	}
+	if d.statements.insertAuditEntry, err = d.db.Prepare(d.driverStatement(insertAuditEntry, sqliteInsertAuditEntry)); err != nil {
+		return nil, err
+	}
+	if d.statements.selectAuditEntries, err = d.db.Prepare(selectAuditEntries); err != nil {
+		return nil, err
+	}
+	if d.statements.upsertState, err = d.db.Prepare(upsertState); err != nil {
+		return nil, err
+	}
+	if d.statements.selectState, err = d.db.Prepare(selectState); err != nil {
+		return nil, err
 	}
 
-	dbInstance = d
-	return nil
+	return d, nil
 }
 
-func (d *Database) GetConfig(ctx context.Context, domain string) (models.ReactConfig, error) {
+// isUniqueViolation determines if the error is because a unique constraint would have been violated
+func isUniqueViolation(err error) (bool) {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code.Name() == "unique_violation"
+	}
+	if sqliteErr, ok := err.(sqlite3.Error); ok {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
+
+// driverStatement picks the version of a statement for the database's driver
+func (d *Database) driverStatement(postgres string, sqlite string) (string) {
+	if d.driver == config.DriverSqlite {
+		return sqlite
+	}
+	return postgres
+}
+
+func (d *Database) GetConfig(ctx context.Context, domain string) (*ConfigRecord, error) {
 	configStr := ""
-	err := d.statements.selectConfig.QueryRowContext(ctx, domain).Scan(&configStr)
-	if err != nil {
+	metadataStr := ""
+	record := &ConfigRecord{Domain: domain}
+	err := d.statements.selectConfig.QueryRowContext(ctx, domain).Scan(&configStr, &metadataStr, &record.Version, &record.UpdatedTs)
+	if err == sql.ErrNoRows {
+		return nil, ErrConfigNotFound
+	} else if err != nil {
 		return nil, err
 	}
 
-	reactConfig := models.ReactConfig{}
-	err = json.Unmarshal([]byte(configStr), &reactConfig)
-	return reactConfig, err
+	return record, unmarshalRecord(record, configStr, metadataStr)
 }
 
-func (d *Database) UpsertConfig(ctx context.Context, domain string, config models.ReactConfig) (error) {
+func unmarshalRecord(record *ConfigRecord, configStr string, metadataStr string) (error) {
+	record.Config = models.ReactConfig{}
+	err := json.Unmarshal([]byte(configStr), &record.Config)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(metadataStr), &record.Metadata)
+}
+
+// stmt gets the prepared statement for use in the transaction, if there is one
+func stmt(ctx context.Context, tx *sql.Tx, statement *sql.Stmt) (*sql.Stmt) {
+	if tx == nil {
+		return statement
+	}
+	return tx.StmtContext(ctx, statement)
+}
+
+// inTransaction calls fn in a new transaction, which is committed if fn succeeds and rolled back if not
+func (d *Database) inTransaction(ctx context.Context, fn func(tx *sql.Tx) (error)) (error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *Database) UpsertConfig(ctx context.Context, domain string, config models.ReactConfig, metadata models.ConfigMetadata, expectedVersion int64) (error) {
+	return d.upsertConfig(ctx, nil, domain, config, metadata, expectedVersion)
+}
+
+func (d *Database) UpsertAuditedConfig(ctx context.Context, domain string, config models.ReactConfig, metadata models.ConfigMetadata, expectedVersion int64, entry *models.AuditEntry) (error) {
+	return d.inTransaction(ctx, func(tx *sql.Tx) (error) {
+		err := d.insertAuditEntry(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
+		return d.upsertConfig(ctx, tx, domain, config, metadata, expectedVersion)
+	})
+}
+
+func (d *Database) upsertConfig(ctx context.Context, tx *sql.Tx, domain string, config models.ReactConfig, metadata models.ConfigMetadata, expectedVersion int64) (error) {
 	configStr, err := json.Marshal(config)
 	if err != nil {
 		return err
 	}
+	metadataStr, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	err = d.checkMatcherChange(ctx, tx, domain, metadata)
+	if err != nil {
+		return err
+	}
+
+	var res sql.Result
+	updatedTs := util.NowMillis()
+	switch expectedVersion {
+	case AnyVersion:
+		_, err = stmt(ctx, tx, d.statements.upsertConfig).ExecContext(ctx, domain, string(configStr), string(metadataStr), updatedTs)
+		return err
+	case NoVersion:
+		res, err = stmt(ctx, tx, d.statements.insertConfig).ExecContext(ctx, domain, string(configStr), string(metadataStr), updatedTs)
+		break
+	default:
+		res, err = stmt(ctx, tx, d.statements.updateConfig).ExecContext(ctx, string(configStr), string(metadataStr), updatedTs, domain, expectedVersion)
+		break
+	}
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(res)
+}
+
+func (d *Database) DeleteConfig(ctx context.Context, domain string, expectedVersion int64) (error) {
+	return d.deleteConfig(ctx, nil, domain, expectedVersion)
+}
+
+func (d *Database) DeleteAuditedConfig(ctx context.Context, domain string, expectedVersion int64, entry *models.AuditEntry) (error) {
+	return d.inTransaction(ctx, func(tx *sql.Tx) (error) {
+		err := d.insertAuditEntry(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
+		return d.deleteConfig(ctx, tx, domain, expectedVersion)
+	})
+}
+
+func (d *Database) deleteConfig(ctx context.Context, tx *sql.Tx, domain string, expectedVersion int64) (error) {
+	if expectedVersion == AnyVersion {
+		res, err := stmt(ctx, tx, d.statements.deleteConfig).ExecContext(ctx, domain)
+		if err != nil {
+			return err
+		}
+		return d.markRemovedIfAffected(ctx, tx, res)
+	}
 
-	_, err = d.statements.upsertConfig.ExecContext(ctx, domain, string(configStr))
-	return err
+	res, err := stmt(ctx, tx, d.statements.deleteConfigVersion).ExecContext(ctx, domain, expectedVersion)
+	if err != nil {
+		return err
+	}
+	err = expectOneRow(res)
+	if err != nil {
+		return err
+	}
+	return d.markRemoved(ctx, tx)
 }
 
-func (d *Database) DeleteConfig(ctx context.Context, domain string) (error) {
-	_, err := d.statements.deleteConfig.ExecContext(ctx, domain)
-	return err
+func expectOneRow(res sql.Result) (error) {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return ErrVersionMismatch
+	}
+	return nil
 }
 
 func (d *Database) ListGlobs(ctx context.Context) ([]string, error) {
 	rows, err := d.statements.selectGlobs.QueryContext(ctx)
-	if err == sql.ErrNoRows {
-		return make([]string, 0), nil
-	} else if err != nil {
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	results := make([]string, 0)
 	var scanString string
 	for rows.Next() {
-		rows.Scan(&scanString)
+		err = rows.Scan(&scanString)
+		if err != nil {
+			return nil, err
+		}
 		results = append(results, scanString)
 	}
 
-	return results, err
+	return results, rows.Err()
+}
+
+func (d *Database) ListConfigs(ctx context.Context) ([]*ConfigRecord, error) {
+	rows, err := d.statements.selectConfigs.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*ConfigRecord, 0)
+	for rows.Next() {
+		configStr := ""
+		metadataStr := ""
+		record := &ConfigRecord{}
+		err = rows.Scan(&record.Domain, &configStr, &metadataStr, &record.Version, &record.UpdatedTs)
+		if err != nil {
+			return nil, err
+		}
+		err = unmarshalRecord(record, configStr, metadataStr)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, record)
+	}
+
+	return results, rows.Err()
+}
+
+func (d *Database) ImportConfigs(ctx context.Context, upserts []*ConfigRecord, deletes []string) (error) {
+	return d.inTransaction(ctx, func(tx *sql.Tx) (error) {
+		return d.importConfigs(ctx, tx, upserts, deletes)
+	})
+}
+
+func (d *Database) ImportAuditedConfigs(ctx context.Context, upserts []*ConfigRecord, deletes []string, entries []*models.AuditEntry) (error) {
+	return d.inTransaction(ctx, func(tx *sql.Tx) (error) {
+		for _, entry := range entries {
+			err := d.insertAuditEntry(ctx, tx, entry)
+			if err != nil {
+				return err
+			}
+		}
+		return d.importConfigs(ctx, tx, upserts, deletes)
+	})
+}
+
+func (d *Database) importConfigs(ctx context.Context, tx *sql.Tx, upserts []*ConfigRecord, deletes []string) (error) {
+	updatedTs := util.NowMillis()
+	for _, record := range upserts {
+		configStr, err := json.Marshal(record.Config)
+		if err != nil {
+			return err
+		}
+		metadataStr, err := json.Marshal(record.Metadata)
+		if err != nil {
+			return err
+		}
+		err = d.checkMatcherChange(ctx, tx, record.Domain, record.Metadata)
+		if err != nil {
+			return err
+		}
+		_, err = stmt(ctx, tx, d.statements.upsertConfig).ExecContext(ctx, record.Domain, string(configStr), string(metadataStr), updatedTs)
+		if err != nil {
+			return err
+		}
+	}
+	for _, domain := range deletes {
+		res, err := stmt(ctx, tx, d.statements.deleteConfig).ExecContext(ctx, domain)
+		if err != nil {
+			return err
+		}
+		err = d.markRemovedIfAffected(ctx, tx, res)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
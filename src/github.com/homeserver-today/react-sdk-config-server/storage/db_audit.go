@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+// insertAuditEntry records the entry in the transaction, so that it is only kept if the change is made
+func (d *Database) insertAuditEntry(ctx context.Context, tx *sql.Tx, entry *models.AuditEntry) (error) {
+	diffStr, err := json.Marshal(entry.Diff)
+	if err != nil {
+		return err
+	}
+
+	// The database assigns the ID. The Postgres driver can't tell us the last inserted ID, so the
+	// statement returns it instead.
+	statement := tx.StmtContext(ctx, d.statements.insertAuditEntry)
+	if d.driver != config.DriverSqlite {
+		return statement.QueryRowContext(ctx, entry.CreatedTs, entry.Actor, entry.RemoteAddr, entry.Domain, entry.Action, string(diffStr)).Scan(&entry.Id)
+	}
+
+	res, err := statement.ExecContext(ctx, entry.CreatedTs, entry.Actor, entry.RemoteAddr, entry.Domain, entry.Action, string(diffStr))
+	if err != nil {
+		return err
+	}
+	entry.Id, err = res.LastInsertId()
+	return err
+}
+
+func (d *Database) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	rows, err := d.statements.selectAuditEntries.QueryContext(ctx, filter.Domain, filter.Actor, filter.SinceTs, filter.UntilTs, filter.BeforeId, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*models.AuditEntry, 0)
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		diffStr := ""
+		err = rows.Scan(&entry.Id, &entry.CreatedTs, &entry.Actor, &entry.RemoteAddr, &entry.Domain, &entry.Action, &diffStr)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(diffStr), &entry.Diff)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, entry)
+	}
+
+	return results, rows.Err()
+}
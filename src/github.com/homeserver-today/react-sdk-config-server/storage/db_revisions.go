@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+// How many times to try inserting a revision when other servers keep taking the revision number
+const maxRevisionAttempts = 5
+
+func (d *Database) InsertRevision(ctx context.Context, revision *models.ConfigRevision) (error) {
+	var configStr sql.NullString
+	if revision.Config != nil {
+		configBytes, err := json.Marshal(revision.Config)
+		if err != nil {
+			return err
+		}
+		configStr = sql.NullString{String: string(configBytes), Valid: true}
+	}
+	var metadataStr sql.NullString
+	if revision.Metadata != nil {
+		metadataBytes, err := json.Marshal(revision.Metadata)
+		if err != nil {
+			return err
+		}
+		metadataStr = sql.NullString{String: string(metadataBytes), Valid: true}
+	}
+
+	// Another server may take the same revision number between us finding the latest one and inserting
+	// ours, in which case the unique constraint stops us and we try again with the next number
+	for attempt := 1; ; attempt++ {
+		err := d.insertNextRevision(ctx, revision, configStr, metadataStr)
+		if err == nil || !isUniqueViolation(err) || attempt >= maxRevisionAttempts {
+			return err
+		}
+	}
+}
+
+func (d *Database) insertNextRevision(ctx context.Context, revision *models.ConfigRevision, configStr sql.NullString, metadataStr sql.NullString) (error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	latest := 0
+	err = tx.StmtContext(ctx, d.statements.selectLatestRevision).QueryRowContext(ctx, revision.Domain).Scan(&latest)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	revision.Revision = latest + 1
+	_, err = tx.StmtContext(ctx, d.statements.insertRevision).ExecContext(ctx, revision.Domain, revision.Revision, revision.CreatedTs, revision.Actor, revision.Action, configStr, metadataStr)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d *Database) ListRevisions(ctx context.Context, domain string) ([]*models.ConfigRevision, error) {
+	rows, err := d.statements.selectRevisions.QueryContext(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*models.ConfigRevision, 0)
+	for rows.Next() {
+		revision := &models.ConfigRevision{}
+		err = rows.Scan(&revision.Domain, &revision.Revision, &revision.CreatedTs, &revision.Actor, &revision.Action)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, revision)
+	}
+
+	return results, rows.Err()
+}
+
+func (d *Database) GetRevision(ctx context.Context, domain string, revisionNumber int) (*models.ConfigRevision, error) {
+	revision := &models.ConfigRevision{}
+	var configStr sql.NullString
+	var metadataStr sql.NullString
+	err := d.statements.selectRevision.QueryRowContext(ctx, domain, revisionNumber).Scan(&revision.Domain, &revision.Revision, &revision.CreatedTs, &revision.Actor, &revision.Action, &configStr, &metadataStr)
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if configStr.Valid {
+		revision.Config = models.ReactConfig{}
+		err = json.Unmarshal([]byte(configStr.String), &revision.Config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if metadataStr.Valid {
+		revision.Metadata = &models.ConfigMetadata{}
+		err = json.Unmarshal([]byte(metadataStr.String), revision.Metadata)
+	}
+	return revision, err
+}
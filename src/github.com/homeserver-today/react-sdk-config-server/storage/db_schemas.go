@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+func (d *Database) UpsertSchema(ctx context.Context, schema *models.StoredSchema) (error) {
+	_, err := d.statements.upsertSchema.ExecContext(ctx, schema.Name, string(schema.Schema), schema.UpdatedTs)
+	return err
+}
+
+func (d *Database) GetSchema(ctx context.Context, name string) (*models.StoredSchema, error) {
+	return scanSchema(d.statements.selectSchema.QueryRowContext(ctx, name))
+}
+
+func (d *Database) ListSchemas(ctx context.Context) ([]*models.StoredSchema, error) {
+	rows, err := d.statements.selectSchemas.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*models.StoredSchema, 0)
+	for rows.Next() {
+		schema, err := scanSchema(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, schema)
+	}
+
+	return results, rows.Err()
+}
+
+func (d *Database) DeleteSchema(ctx context.Context, name string) (error) {
+	res, err := d.statements.deleteSchema.ExecContext(ctx, name)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return ErrSchemaNotFound
+	}
+	return nil
+}
+
+func scanSchema(row rowScanner) (*models.StoredSchema, error) {
+	schema := &models.StoredSchema{}
+	schemaStr := ""
+	err := row.Scan(&schema.Name, &schemaStr, &schema.UpdatedTs)
+	if err == sql.ErrNoRows {
+		return nil, ErrSchemaNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	schema.Schema = []byte(schemaStr)
+	return schema, nil
+}
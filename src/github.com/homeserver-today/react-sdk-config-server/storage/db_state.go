@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+)
+
+// Names of the values kept in the store_state table
+const stateRemovedTs = "removed_ts"
+const stateLegacyMetadataMigratedTs = "legacy_metadata_migrated_ts"
+
+// getState gets a value from the store_state table, or 0 if it has never been set
+func (d *Database) getState(ctx context.Context, name string) (int64, error) {
+	value := int64(0)
+	err := d.statements.selectState.QueryRowContext(ctx, name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return value, err
+}
+
+func (d *Database) setState(ctx context.Context, tx *sql.Tx, name string, value int64) (error) {
+	_, err := stmt(ctx, tx, d.statements.upsertState).ExecContext(ctx, name, value)
+	return err
+}
+
+func (d *Database) GetRemovedTs(ctx context.Context) (int64, error) {
+	return d.getState(ctx, stateRemovedTs)
+}
+
+// migrateLegacyMetadataOnce runs migrateLegacyMetadata if it hasn't already finished for the database
+func (d *Database) migrateLegacyMetadataOnce() (error) {
+	ctx := context.Background()
+	migratedTs, err := d.getState(ctx, stateLegacyMetadataMigratedTs)
+	if err != nil {
+		return err
+	}
+	if migratedTs != 0 {
+		return nil
+	}
+
+	err = migrateLegacyMetadata(d)
+	if err != nil {
+		return err
+	}
+	return d.setState(ctx, nil, stateLegacyMetadataMigratedTs, util.NowMillis())
+}
+
+// markRemoved records that a stored config has just stopped applying to domains
+func (d *Database) markRemoved(ctx context.Context, tx *sql.Tx) (error) {
+	return d.setState(ctx, tx, stateRemovedTs, util.NowMillis())
+}
+
+// markRemovedIfAffected calls markRemoved if the statement deleted anything
+func (d *Database) markRemovedIfAffected(ctx context.Context, tx *sql.Tx, res sql.Result) (error) {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return nil
+	}
+	return d.markRemoved(ctx, tx)
+}
+
+// checkMatcherChange calls markRemoved if the glob's stored matcher is about to be replaced, as the
+// template may no longer apply to the domains it did. It must be called before the glob is written.
+func (d *Database) checkMatcherChange(ctx context.Context, tx *sql.Tx, domain string, metadata models.ConfigMetadata) (error) {
+	if !strings.Contains(domain, "*") {
+		return nil
+	}
+
+	configStr := ""
+	metadataStr := ""
+	record := &ConfigRecord{Domain: domain}
+	err := stmt(ctx, tx, d.statements.selectConfig).QueryRowContext(ctx, domain).Scan(&configStr, &metadataStr, &record.Version, &record.UpdatedTs)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = unmarshalRecord(record, configStr, metadataStr)
+	if err != nil {
+		return err
+	}
+
+	if !matcherChanged(record.Metadata, metadata) {
+		return nil
+	}
+	return d.markRemoved(ctx, tx)
+}
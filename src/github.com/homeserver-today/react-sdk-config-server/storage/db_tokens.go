@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+type rowScanner interface {
+	Scan(dest ...interface{}) (error)
+}
+
+func (d *Database) InsertToken(ctx context.Context, token *models.StoredApiToken) (error) {
+	domainsStr, err := json.Marshal(token.Domains)
+	if err != nil {
+		return err
+	}
+	permissionsStr, err := json.Marshal(token.Permissions)
+	if err != nil {
+		return err
+	}
+
+	// Checking first gives a friendlier error in most cases, but the unique constraints are what protect us
+	_, err = d.GetTokenByName(ctx, token.Name)
+	if err == nil {
+		return ErrTokenExists
+	} else if err != ErrTokenNotFound {
+		return err
+	}
+
+	_, err = d.statements.insertToken.ExecContext(ctx, token.Id, token.Name, token.Salt, token.Hash, string(domainsStr), string(permissionsStr), token.CreatedTs, token.ExpiresTs, token.Revoked)
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unique") {
+		return ErrTokenExists
+	}
+	return err
+}
+
+func (d *Database) GetTokenById(ctx context.Context, id string) (*models.StoredApiToken, error) {
+	return scanToken(d.statements.selectTokenById.QueryRowContext(ctx, id))
+}
+
+func (d *Database) GetTokenByName(ctx context.Context, name string) (*models.StoredApiToken, error) {
+	return scanToken(d.statements.selectTokenByName.QueryRowContext(ctx, name))
+}
+
+func (d *Database) ListTokens(ctx context.Context) ([]*models.StoredApiToken, error) {
+	rows, err := d.statements.selectTokens.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*models.StoredApiToken, 0)
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, token)
+	}
+
+	return results, rows.Err()
+}
+
+func (d *Database) UpdateToken(ctx context.Context, token *models.StoredApiToken) (error) {
+	domainsStr, err := json.Marshal(token.Domains)
+	if err != nil {
+		return err
+	}
+	permissionsStr, err := json.Marshal(token.Permissions)
+	if err != nil {
+		return err
+	}
+
+	res, err := d.statements.updateToken.ExecContext(ctx, string(domainsStr), string(permissionsStr), token.ExpiresTs, token.Revoked, token.Id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+func scanToken(row rowScanner) (*models.StoredApiToken, error) {
+	token := &models.StoredApiToken{}
+	domainsStr := ""
+	permissionsStr := ""
+	err := row.Scan(&token.Id, &token.Name, &token.Salt, &token.Hash, &domainsStr, &permissionsStr, &token.CreatedTs, &token.ExpiresTs, &token.Revoked)
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(domainsStr), &token.Domains)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(permissionsStr), &token.Permissions)
+	return token, err
+}
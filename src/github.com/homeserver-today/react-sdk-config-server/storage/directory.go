@@ -0,0 +1,454 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"github.com/fsnotify/fsnotify"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+	"github.com/sirupsen/logrus"
+)
+
+const configFileExtension = ".json"
+
+var ErrInvalidDomain = errors.New("domain cannot be used as a file name")
+
+// DirectoryStore reads and writes configs as <hostname>.json files in a single directory. The
+// directory is watched for changes so that edits made on disk (eg: by pulling from git) are
+// picked up without restarting the server. A hash of a file's config and metadata is used as its
+// version, so the version only changes when the content does. Metadata is kept in the files as
+// hstoday.* keys, and the audit log is kept in a hidden file alongside them.
+type DirectoryStore struct {
+	directory string
+	watcher   *fsnotify.Watcher
+
+	// Held while checking a file's version and replacing it, and while using the audit log
+	lock    *sync.Mutex
+	auditId int64
+}
+
+func OpenDirectoryStore(directory string) (*DirectoryStore, error) {
+	err := os.MkdirAll(directory, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	err = watcher.Add(directory)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	s := &DirectoryStore{
+		directory: directory,
+		watcher:   watcher,
+		lock:      &sync.Mutex{},
+	}
+	err = s.loadAuditId()
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	go s.watch()
+
+	return s, nil
+}
+
+func (s *DirectoryStore) watch() {
+	log := logrus.WithFields(logrus.Fields{"directory": s.directory})
+	log.Info("Watching for config changes")
+
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+
+			domain, isConfig := domainFromFileName(path.Base(event.Name))
+			if !isConfig {
+				continue
+			}
+
+			log.Info("Config file for " + domain + " changed on disk (" + event.Op.String() + ")")
+			invalidateDomain(domain)
+			break
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Error("Error watching config directory: ", err)
+			break
+		}
+	}
+}
+
+func domainFromFileName(fileName string) (string, bool) {
+	// Ignore hidden files, such as the temporary files we (and most editors) write
+	if strings.HasPrefix(fileName, ".") || !strings.HasSuffix(fileName, configFileExtension) {
+		return "", false
+	}
+
+	return fileName[:len(fileName)-len(configFileExtension)], true
+}
+
+func (s *DirectoryStore) fileName(domain string) (string, error) {
+	if domain == "" || strings.HasPrefix(domain, ".") || strings.ContainsAny(domain, "/\\") {
+		return "", ErrInvalidDomain
+	}
+
+	return path.Join(s.directory, domain+configFileExtension), nil
+}
+
+func (s *DirectoryStore) GetConfig(ctx context.Context, domain string) (*ConfigRecord, error) {
+	fileName, err := s.fileName(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return nil, ErrConfigNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	configBytes, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &ConfigRecord{
+		Domain:    domain,
+		UpdatedTs: util.ToMillis(stat.ModTime()),
+		Config:    models.ReactConfig{},
+	}
+	err = json.Unmarshal(configBytes, &record.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	record.Config, _, err = models.ExtractMetadata(record.Config, &record.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	etag, err := record.ETag()
+	if err != nil {
+		return nil, err
+	}
+	record.Version, err = versionFromETag(etag)
+	return record, err
+}
+
+// versionFromETag turns the first 8 bytes of the ETag's hash into a version, avoiding the versions
+// which have a special meaning.
+func versionFromETag(etag string) (int64, error) {
+	hash, err := hex.DecodeString(strings.Trim(etag, "\""))
+	if err != nil {
+		return 0, err
+	}
+	if len(hash) < 8 {
+		return 0, errors.New("etag is too short to be used as a version")
+	}
+
+	version := int64(binary.BigEndian.Uint64(hash[:8]) & math.MaxInt64)
+	if version == NoVersion {
+		version = 1
+	}
+	return version, nil
+}
+
+// checkVersion makes sure the domain's stored config is at the expected version. The lock must be
+// held, so that nothing else can change the file through the store until the change has been made.
+func (s *DirectoryStore) checkVersion(ctx context.Context, domain string, expectedVersion int64) (error) {
+	if expectedVersion == AnyVersion {
+		return nil
+	}
+
+	currentVersion := NoVersion
+	record, err := s.GetConfig(ctx, domain)
+	if err == nil {
+		currentVersion = record.Version
+	} else if err != ErrConfigNotFound {
+		return err
+	}
+
+	if currentVersion != expectedVersion {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+func (s *DirectoryStore) UpsertConfig(ctx context.Context, domain string, config models.ReactConfig, metadata models.ConfigMetadata, expectedVersion int64) (error) {
+	return s.UpsertAuditedConfig(ctx, domain, config, metadata, expectedVersion, nil)
+}
+
+func (s *DirectoryStore) UpsertAuditedConfig(ctx context.Context, domain string, config models.ReactConfig, metadata models.ConfigMetadata, expectedVersion int64, entry *models.AuditEntry) (error) {
+	fileName, err := s.fileName(domain)
+	if err != nil {
+		return err
+	}
+
+	configBytes, err := json.MarshalIndent(metadata.WithLegacyKeys(config), "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so the watcher (and readers) never see a partial config
+	tmpFile, err := ioutil.TempFile(s.directory, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(append(configBytes, '\n'))
+	if err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	err = tmpFile.Close()
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+
+	// The version is checked as late as possible to leave little time for the file to be edited on disk
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	err = s.checkVersion(ctx, domain, expectedVersion)
+	var undoAudit func()
+	if err == nil {
+		undoAudit, err = s.appendAudit(entry)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), fileName)
+		if err != nil {
+			undoAudit()
+		}
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+	}
+	return err
+}
+
+func (s *DirectoryStore) DeleteConfig(ctx context.Context, domain string, expectedVersion int64) (error) {
+	return s.DeleteAuditedConfig(ctx, domain, expectedVersion, nil)
+}
+
+func (s *DirectoryStore) DeleteAuditedConfig(ctx context.Context, domain string, expectedVersion int64, entry *models.AuditEntry) (error) {
+	fileName, err := s.fileName(domain)
+	if err != nil {
+		return err
+	}
+
+	if expectedVersion == NoVersion {
+		return ErrVersionMismatch
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	err = s.checkVersion(ctx, domain, expectedVersion)
+	if err != nil {
+		return err
+	}
+	undoAudit, err := s.appendAudit(entry)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		undoAudit()
+	}
+	return err
+}
+
+func (s *DirectoryStore) ListGlobs(ctx context.Context) ([]string, error) {
+	files, err := ioutil.ReadDir(s.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]string, 0)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		domain, isConfig := domainFromFileName(f.Name())
+		if isConfig && strings.Contains(domain, "*") {
+			results = append(results, domain)
+		}
+	}
+
+	return results, nil
+}
+
+func (s *DirectoryStore) ListConfigs(ctx context.Context) ([]*ConfigRecord, error) {
+	files, err := ioutil.ReadDir(s.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ConfigRecord, 0)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		domain, isConfig := domainFromFileName(f.Name())
+		if !isConfig {
+			continue
+		}
+
+		record, err := s.GetConfig(ctx, domain)
+		if err == ErrConfigNotFound {
+			continue // deleted since we listed the directory
+		} else if err != nil {
+			return nil, err
+		}
+		results = append(results, record)
+	}
+
+	return results, nil
+}
+
+// GetRemovedTs uses the directory's modification time, which changes whenever a file is removed from
+// it (including on disk). Files being added or renamed into place change it too, which errs towards
+// configs being treated as changed.
+func (s *DirectoryStore) GetRemovedTs(ctx context.Context) (int64, error) {
+	stat, err := os.Stat(s.directory)
+	if err != nil {
+		return 0, err
+	}
+	return util.ToMillis(stat.ModTime()), nil
+}
+
+// ImportConfigs writes every imported config to a temporary directory before moving them into place,
+// so that a bad config doesn't leave a partial import. Files which are replaced or deleted are kept
+// in the temporary directory until the import is done, and are put back if any of the moves fail.
+func (s *DirectoryStore) ImportConfigs(ctx context.Context, upserts []*ConfigRecord, deletes []string) (error) {
+	return s.ImportAuditedConfigs(ctx, upserts, deletes, nil)
+}
+
+func (s *DirectoryStore) ImportAuditedConfigs(ctx context.Context, upserts []*ConfigRecord, deletes []string, entries []*models.AuditEntry) (error) {
+	// The temporary directory is hidden, and the watcher doesn't look inside it
+	tmpDir, err := ioutil.TempDir(s.directory, ".import-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	newDir := path.Join(tmpDir, "new")
+	oldDir := path.Join(tmpDir, "old")
+	for _, dir := range []string{newDir, oldDir} {
+		err = os.Mkdir(dir, 0700)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, record := range upserts {
+		fileName, err := s.fileName(record.Domain)
+		if err != nil {
+			return err
+		}
+		configBytes, err := json.MarshalIndent(record.Metadata.WithLegacyKeys(record.Config), "", "    ")
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(path.Join(newDir, path.Base(fileName)), append(configBytes, '\n'), 0600)
+		if err != nil {
+			return err
+		}
+	}
+	for _, domain := range deletes {
+		if _, err = s.fileName(domain); err != nil {
+			return err
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	undoAudit, err := s.appendAudit(entries...)
+	if err != nil {
+		return err
+	}
+
+	// Each change made so far, and how to undo it
+	undo := []func(){undoAudit}
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+
+	for _, record := range upserts {
+		name := path.Base(record.Domain + configFileExtension)
+		fileName := path.Join(s.directory, name)
+		backupName := path.Join(oldDir, name)
+
+		// Existing files are linked rather than moved so that readers never find them missing
+		err = os.Link(fileName, backupName)
+		existed := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			rollback()
+			return err
+		}
+
+		err = os.Rename(path.Join(newDir, name), fileName)
+		if err != nil {
+			rollback()
+			return err
+		}
+		undo = append(undo, func() {
+			if existed {
+				os.Rename(backupName, fileName)
+			} else {
+				os.Remove(fileName)
+			}
+		})
+	}
+	for _, domain := range deletes {
+		name := path.Base(domain + configFileExtension)
+		fileName := path.Join(s.directory, name)
+		backupName := path.Join(oldDir, name)
+
+		err = os.Rename(fileName, backupName)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			rollback()
+			return err
+		}
+		undo = append(undo, func() {
+			os.Rename(backupName, fileName)
+		})
+	}
+
+	return nil
+}
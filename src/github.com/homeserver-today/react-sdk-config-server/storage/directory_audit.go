@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+// The audit log is kept in the config directory as one JSON entry per line. The file is hidden, so
+// it is never mistaken for a config.
+const auditFileName = ".audit.log"
+
+func (s *DirectoryStore) auditFile() (string) {
+	return path.Join(s.directory, auditFileName)
+}
+
+// readAudit gets every entry in the audit log, oldest first
+func (s *DirectoryStore) readAudit() ([]*models.AuditEntry, error) {
+	f, err := os.Open(s.auditFile())
+	if os.IsNotExist(err) {
+		return make([]*models.AuditEntry, 0), nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	results := make([]*models.AuditEntry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := &models.AuditEntry{}
+		err = json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, entry)
+	}
+
+	return results, scanner.Err()
+}
+
+// loadAuditId finds the ID of the newest entry in the audit log, so that new entries carry on from it
+func (s *DirectoryStore) loadAuditId() (error) {
+	entries, err := s.readAudit()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Id > s.auditId {
+			s.auditId = entry.Id
+		}
+	}
+	return nil
+}
+
+// appendAudit assigns the entries IDs and adds them to the end of the audit log, returning a function
+// which removes them again if the change they describe can't be made. The lock must be held. Files
+// can't be written together, so the entries are written first: if the server stops before the change
+// is made, the audit log describes a change which didn't happen rather than missing one which did.
+func (s *DirectoryStore) appendAudit(entries ...*models.AuditEntry) (func(), error) {
+	lines := make([]byte, 0)
+	nextId := s.auditId
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		nextId++
+		stored := *entry
+		stored.Id = nextId
+		entryBytes, err := json.Marshal(stored)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(append(lines, entryBytes...), '\n')
+	}
+	if len(lines) == 0 {
+		return func() {}, nil
+	}
+
+	f, err := os.OpenFile(s.auditFile(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	previousSize := stat.Size()
+	previousId := s.auditId
+
+	undo := func() {
+		os.Truncate(s.auditFile(), previousSize)
+		s.auditId = previousId
+	}
+
+	_, err = f.Write(lines)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		undo()
+		return nil, err
+	}
+
+	nextId = previousId
+	for _, entry := range entries {
+		if entry != nil {
+			nextId++
+			entry.Id = nextId
+		}
+	}
+	s.auditId = nextId
+	return undo, nil
+}
+
+func (s *DirectoryStore) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	s.lock.Lock()
+	entries, err := s.readAudit()
+	s.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*models.AuditEntry, 0)
+	for i := len(entries) - 1; i >= 0 && len(results) < filter.Limit; i-- {
+		if filter.Matches(entries[i]) {
+			results = append(results, entries[i])
+		}
+	}
+
+	return results, nil
+}
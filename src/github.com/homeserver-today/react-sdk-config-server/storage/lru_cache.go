@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache holds up to maxEntries entries (or any number, if maxEntries is 0), evicting the least
+// recently used entry to make room for new ones. Entries also expire once their TTL has passed, unless
+// the TTL is 0. A disabled cache never holds anything.
+type lruCache struct {
+	lock       sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List // most recently used first
+	ttl        time.Duration
+	maxEntries int
+	disabled   bool
+
+	// Called with the number of entries evicted to make room for a new one
+	onEvict func(count int)
+}
+
+type lruEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func newLruCache(ttl time.Duration, maxEntries int, enabled bool) (*lruCache) {
+	return &lruCache{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		disabled:   !enabled,
+		onEvict:    func(count int) {},
+	}
+}
+
+func (e *lruEntry) expired(now time.Time) (bool) {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// Get gets the entry for the key, marking it as recently used
+func (c *lruCache) Get(key string) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if entry.expired(time.Now()) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Set stores the value for the cache's TTL
+func (c *lruCache) Set(key string, value interface{}) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL stores the value for the given amount of time, or until it is evicted if ttl is 0
+func (c *lruCache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	if c.disabled {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry := &lruEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	if element, found := c.entries[key]; found {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+
+	evicted := 0
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+		evicted++
+	}
+	if evicted > 0 {
+		c.onEvict(evicted)
+	}
+}
+
+// Delete removes the entry for the key, returning whether there was one
+func (c *lruCache) Delete(key string) (bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, found := c.entries[key]
+	if found {
+		c.remove(element)
+	}
+	return found
+}
+
+// Items gets a copy of the entries which have not expired, without marking them as used
+func (c *lruCache) Items() (map[string]interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	items := make(map[string]interface{}, len(c.entries))
+	for key, element := range c.entries {
+		entry := element.Value.(*lruEntry)
+		if !entry.expired(now) {
+			items[key] = entry.value
+		}
+	}
+	return items
+}
+
+// ItemCount gets the number of entries, including any which have expired but not been removed yet
+func (c *lruCache) ItemCount() (int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.entries)
+}
+
+// DeleteExpired removes every entry which has expired
+func (c *lruCache) DeleteExpired() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	for _, element := range c.entries {
+		if element.Value.(*lruEntry).expired(now) {
+			c.remove(element)
+		}
+	}
+}
+
+// remove removes the element from the cache. The lock must be held.
+func (c *lruCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+	"github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/sirupsen/logrus"
+)
+
+// useTestConfig loads the YAML as the server's config, on top of the defaults
+func useTestConfig(t *testing.T, yaml string) {
+	dir, err := ioutil.TempDir("", "config-server-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config.Path = path.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(config.Path, []byte(yaml), 0600)
+	if err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	err = config.ReloadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+}
+
+func TestLruCacheExpiry(t *testing.T) {
+	c := newLruCache(20*time.Millisecond, 0, true)
+	c.Set("default", 1)
+	c.SetWithTTL("longer", 2, time.Hour)
+	c.SetWithTTL("forever", 3, 0)
+
+	time.Sleep(40 * time.Millisecond)
+
+	tests := []struct {
+		key   string
+		found bool
+	}{
+		{"default", false},
+		{"longer", true},
+		{"forever", true},
+	}
+	for _, test := range tests {
+		if _, found := c.Get(test.key); found != test.found {
+			t.Errorf("expected %s found to be %v", test.key, test.found)
+		}
+	}
+
+	c.Set("expiring", 4)
+	time.Sleep(40 * time.Millisecond)
+	if _, found := c.Items()["expiring"]; found {
+		t.Error("expected expired entries to be left out of the items")
+	}
+	c.DeleteExpired()
+	if count := c.ItemCount(); count != 2 {
+		t.Errorf("expected 2 entries after removing expired ones, got %d", count)
+	}
+}
+
+func TestLruCacheEviction(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		used       []string // looked up after a, b and c are added
+		expected   []string
+		evicted    int
+	}{
+		{"oldest is evicted", 2, nil, []string{"b", "c"}, 1},
+		{"recently used is kept", 2, []string{"a"}, []string{"a", "c"}, 1},
+		{"several are evicted", 1, nil, []string{"c"}, 2},
+		{"unlimited", 0, nil, []string{"a", "b", "c"}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newLruCache(0, test.maxEntries, true)
+			evicted := 0
+			c.onEvict = func(count int) {
+				evicted += count
+			}
+
+			c.Set("a", 1)
+			c.Set("b", 2)
+			for _, key := range test.used {
+				c.Get(key)
+			}
+			c.Set("c", 3)
+
+			items := c.Items()
+			if len(items) != len(test.expected) {
+				t.Errorf("expected %d entries, got %d", len(test.expected), len(items))
+			}
+			for _, key := range test.expected {
+				if _, found := items[key]; !found {
+					t.Errorf("expected %s to be cached", key)
+				}
+			}
+			if evicted != test.evicted {
+				t.Errorf("expected %d evictions, got %d", test.evicted, evicted)
+			}
+		})
+	}
+}
+
+func TestLruCacheReplaceAndDelete(t *testing.T) {
+	c := newLruCache(0, 2, true)
+	c.Set("a", 1)
+	c.Set("a", 2)
+	if value, _ := c.Get("a"); value != 2 {
+		t.Errorf("expected the replaced value, got %v", value)
+	}
+	if count := c.ItemCount(); count != 1 {
+		t.Errorf("expected replacing to keep 1 entry, got %d", count)
+	}
+
+	if !c.Delete("a") {
+		t.Error("expected deleting a cached entry to report it")
+	}
+	if c.Delete("a") {
+		t.Error("expected deleting a missing entry not to report it")
+	}
+
+	disabled := newLruCache(0, 0, false)
+	disabled.Set("a", 1)
+	if _, found := disabled.Get("a"); found {
+		t.Error("expected a disabled cache to hold nothing")
+	}
+}
+
+func TestCacheResolvedNegativeEntries(t *testing.T) {
+	tests := []struct {
+		name        string
+		negativeTtl int
+		negative    bool
+		cached      bool
+		expiresIn   time.Duration
+	}{
+		{"stored configs use the cache's TTL", 300, false, true, time.Hour},
+		{"negative entries use the negative TTL", 300, true, true, 300 * time.Second},
+		{"negative entries are not cached without a negative TTL", 0, true, false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTestConfig(t, "cache:\n  negativeTtlSeconds: "+strconv.Itoa(test.negativeTtl)+"\n")
+			c := &configCache{cache: newLruCache(time.Hour, 0, true), log: logrus.WithFields(logrus.Fields{})}
+
+			before := time.Now()
+			c.cacheResolved("domain_example.org", &ResolvedConfig{negative: test.negative})
+
+			element, found := c.cache.entries["domain_example.org"]
+			if found != test.cached {
+				t.Fatalf("expected cached to be %v", test.cached)
+			}
+			if !found {
+				return
+			}
+			expiresIn := element.Value.(*lruEntry).expiresAt.Sub(before)
+			if expiresIn < test.expiresIn || expiresIn > test.expiresIn+time.Second {
+				t.Errorf("expected the entry to expire in %s, got %s", test.expiresIn, expiresIn)
+			}
+		})
+	}
+}
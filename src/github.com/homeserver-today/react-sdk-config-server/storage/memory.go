@@ -0,0 +1,431 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+)
+
+// MemoryStore keeps configs in process memory. Configs are held in their serialized form so that
+// callers can freely mutate what they are given without affecting the store.
+type MemoryStore struct {
+	configs   map[string][]byte
+	metadata  map[string]models.ConfigMetadata
+	versions  map[string]int64
+	updated   map[string]int64
+	revisions map[string][][]byte
+	tokens    map[string]models.StoredApiToken
+	schemas   map[string]models.StoredSchema
+	audit     [][]byte
+	auditId   int64
+	removedTs int64
+	lock      *sync.RWMutex
+}
+
+func NewMemoryStore() (*MemoryStore) {
+	return &MemoryStore{
+		configs:   make(map[string][]byte),
+		metadata:  make(map[string]models.ConfigMetadata),
+		versions:  make(map[string]int64),
+		updated:   make(map[string]int64),
+		revisions: make(map[string][][]byte),
+		tokens:    make(map[string]models.StoredApiToken),
+		schemas:   make(map[string]models.StoredSchema),
+		audit:     make([][]byte, 0),
+		lock:      &sync.RWMutex{},
+	}
+}
+
+func (s *MemoryStore) GetConfig(ctx context.Context, domain string) (*ConfigRecord, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	configBytes, found := s.configs[domain]
+	if !found {
+		return nil, ErrConfigNotFound
+	}
+
+	record := &ConfigRecord{Domain: domain, Version: s.versions[domain], UpdatedTs: s.updated[domain], Metadata: s.metadata[domain], Config: models.ReactConfig{}}
+	err := json.Unmarshal(configBytes, &record.Config)
+	return record, err
+}
+
+func (s *MemoryStore) UpsertConfig(ctx context.Context, domain string, config models.ReactConfig, metadata models.ConfigMetadata, expectedVersion int64) (error) {
+	return s.UpsertAuditedConfig(ctx, domain, config, metadata, expectedVersion, nil)
+}
+
+func (s *MemoryStore) UpsertAuditedConfig(ctx context.Context, domain string, config models.ReactConfig, metadata models.ConfigMetadata, expectedVersion int64, entry *models.AuditEntry) (error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	err = s.checkAudit(entry)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if expectedVersion != AnyVersion && s.versions[domain] != expectedVersion {
+		return ErrVersionMismatch
+	}
+
+	s.checkMatcherChange(domain, metadata)
+	s.configs[domain] = configBytes
+	s.metadata[domain] = metadata
+	s.versions[domain] = s.versions[domain] + 1
+	s.updated[domain] = util.NowMillis()
+	s.appendAudit(entry)
+	return nil
+}
+
+func (s *MemoryStore) DeleteConfig(ctx context.Context, domain string, expectedVersion int64) (error) {
+	return s.DeleteAuditedConfig(ctx, domain, expectedVersion, nil)
+}
+
+func (s *MemoryStore) DeleteAuditedConfig(ctx context.Context, domain string, expectedVersion int64, entry *models.AuditEntry) (error) {
+	err := s.checkAudit(entry)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if expectedVersion != AnyVersion && (s.versions[domain] == NoVersion || s.versions[domain] != expectedVersion) {
+		return ErrVersionMismatch
+	}
+
+	s.remove(domain)
+	s.appendAudit(entry)
+	return nil
+}
+
+func (s *MemoryStore) ListGlobs(ctx context.Context) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	results := make([]string, 0)
+	for domain := range s.configs {
+		if strings.Contains(domain, "*") {
+			results = append(results, domain)
+		}
+	}
+
+	return results, nil
+}
+
+func (s *MemoryStore) ListConfigs(ctx context.Context) ([]*ConfigRecord, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	results := make([]*ConfigRecord, 0, len(s.configs))
+	for domain, configBytes := range s.configs {
+		record := &ConfigRecord{Domain: domain, Version: s.versions[domain], UpdatedTs: s.updated[domain], Metadata: s.metadata[domain], Config: models.ReactConfig{}}
+		err := json.Unmarshal(configBytes, &record.Config)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, record)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Domain < results[j].Domain
+	})
+	return results, nil
+}
+
+func (s *MemoryStore) ImportConfigs(ctx context.Context, upserts []*ConfigRecord, deletes []string) (error) {
+	return s.ImportAuditedConfigs(ctx, upserts, deletes, nil)
+}
+
+func (s *MemoryStore) ImportAuditedConfigs(ctx context.Context, upserts []*ConfigRecord, deletes []string, entries []*models.AuditEntry) (error) {
+	// Serialize everything before changing anything so that a bad config doesn't leave a partial import
+	serialized := make(map[string][]byte)
+	metadata := make(map[string]models.ConfigMetadata)
+	for _, record := range upserts {
+		metadata[record.Domain] = record.Metadata
+		configBytes, err := json.Marshal(record.Config)
+		if err != nil {
+			return err
+		}
+		serialized[record.Domain] = configBytes
+	}
+	for _, entry := range entries {
+		err := s.checkAudit(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	updatedTs := util.NowMillis()
+	for domain, configBytes := range serialized {
+		s.checkMatcherChange(domain, metadata[domain])
+		s.configs[domain] = configBytes
+		s.metadata[domain] = metadata[domain]
+		s.versions[domain] = s.versions[domain] + 1
+		s.updated[domain] = updatedTs
+	}
+	for _, domain := range deletes {
+		s.remove(domain)
+	}
+	for _, entry := range entries {
+		s.appendAudit(entry)
+	}
+
+	return nil
+}
+
+// remove deletes the domain's config. The lock must be held.
+func (s *MemoryStore) remove(domain string) {
+	if _, found := s.configs[domain]; !found {
+		return
+	}
+
+	delete(s.configs, domain)
+	delete(s.metadata, domain)
+	delete(s.versions, domain)
+	delete(s.updated, domain)
+	s.removedTs = util.NowMillis()
+}
+
+// checkMatcherChange notes the time if the glob's stored matcher is about to be replaced, as the
+// template may no longer apply to the domains it did. The lock must be held.
+func (s *MemoryStore) checkMatcherChange(domain string, metadata models.ConfigMetadata) {
+	if _, found := s.configs[domain]; found && strings.Contains(domain, "*") && matcherChanged(s.metadata[domain], metadata) {
+		s.removedTs = util.NowMillis()
+	}
+}
+
+func (s *MemoryStore) GetRemovedTs(ctx context.Context) (int64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.removedTs, nil
+}
+
+func (s *MemoryStore) InsertRevision(ctx context.Context, revision *models.ConfigRevision) (error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	revision.Revision = len(s.revisions[revision.Domain]) + 1
+	revisionBytes, err := json.Marshal(revision)
+	if err != nil {
+		return err
+	}
+
+	s.revisions[revision.Domain] = append(s.revisions[revision.Domain], revisionBytes)
+	return nil
+}
+
+func (s *MemoryStore) ListRevisions(ctx context.Context, domain string) ([]*models.ConfigRevision, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	// Newest first, and without the configs themselves to match the other backends
+	stored := s.revisions[domain]
+	results := make([]*models.ConfigRevision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revision := &models.ConfigRevision{}
+		err := json.Unmarshal(stored[i], revision)
+		if err != nil {
+			return nil, err
+		}
+		revision.Config = nil
+		revision.Metadata = nil
+		results = append(results, revision)
+	}
+
+	return results, nil
+}
+
+func (s *MemoryStore) GetRevision(ctx context.Context, domain string, revisionNumber int) (*models.ConfigRevision, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	stored := s.revisions[domain]
+	if revisionNumber < 1 || revisionNumber > len(stored) {
+		return nil, ErrRevisionNotFound
+	}
+
+	revision := &models.ConfigRevision{}
+	err := json.Unmarshal(stored[revisionNumber-1], revision)
+	return revision, err
+}
+
+// Tokens are copied in and out of the map. The slices they hold are never modified in place.
+
+func (s *MemoryStore) InsertToken(ctx context.Context, token *models.StoredApiToken) (error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, t := range s.tokens {
+		if t.Name == token.Name || t.Id == token.Id {
+			return ErrTokenExists
+		}
+	}
+
+	s.tokens[token.Id] = *token
+	return nil
+}
+
+func (s *MemoryStore) GetTokenById(ctx context.Context, id string) (*models.StoredApiToken, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	token, found := s.tokens[id]
+	if !found {
+		return nil, ErrTokenNotFound
+	}
+	return &token, nil
+}
+
+func (s *MemoryStore) GetTokenByName(ctx context.Context, name string) (*models.StoredApiToken, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, t := range s.tokens {
+		if t.Name == name {
+			token := t
+			return &token, nil
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+func (s *MemoryStore) ListTokens(ctx context.Context) ([]*models.StoredApiToken, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	results := make([]*models.StoredApiToken, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		token := t
+		results = append(results, &token)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedTs < results[j].CreatedTs
+	})
+	return results, nil
+}
+
+func (s *MemoryStore) UpdateToken(ctx context.Context, token *models.StoredApiToken) (error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	existing, found := s.tokens[token.Id]
+	if !found {
+		return ErrTokenNotFound
+	}
+
+	existing.Domains = token.Domains
+	existing.Permissions = token.Permissions
+	existing.ExpiresTs = token.ExpiresTs
+	existing.Revoked = token.Revoked
+	s.tokens[token.Id] = existing
+	return nil
+}
+
+// Schemas are copied in and out of the map along with their bytes.
+
+func (s *MemoryStore) UpsertSchema(ctx context.Context, schema *models.StoredSchema) (error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored := *schema
+	stored.Schema = append([]byte{}, schema.Schema...)
+	s.schemas[schema.Name] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetSchema(ctx context.Context, name string) (*models.StoredSchema, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	stored, found := s.schemas[name]
+	if !found {
+		return nil, ErrSchemaNotFound
+	}
+	return copySchema(stored), nil
+}
+
+func (s *MemoryStore) ListSchemas(ctx context.Context) ([]*models.StoredSchema, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	results := make([]*models.StoredSchema, 0, len(s.schemas))
+	for _, stored := range s.schemas {
+		results = append(results, copySchema(stored))
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results, nil
+}
+
+func (s *MemoryStore) DeleteSchema(ctx context.Context, name string) (error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.schemas[name]; !found {
+		return ErrSchemaNotFound
+	}
+	delete(s.schemas, name)
+	return nil
+}
+
+func copySchema(stored models.StoredSchema) (*models.StoredSchema) {
+	schema := stored
+	schema.Schema = append([]byte{}, stored.Schema...)
+	return &schema
+}
+
+// checkAudit makes sure that the entry (if there is one) can be stored before anything is changed
+func (s *MemoryStore) checkAudit(entry *models.AuditEntry) (error) {
+	if entry == nil {
+		return nil
+	}
+	_, err := json.Marshal(entry)
+	return err
+}
+
+// appendAudit assigns the entry an ID and adds it to the audit log. The lock must be held, and the
+// entry must have been checked with checkAudit.
+func (s *MemoryStore) appendAudit(entry *models.AuditEntry) {
+	if entry == nil {
+		return
+	}
+
+	s.auditId++
+	entry.Id = s.auditId
+	entryBytes, _ := json.Marshal(entry)
+	s.audit = append(s.audit, entryBytes)
+}
+
+func (s *MemoryStore) ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	results := make([]*models.AuditEntry, 0)
+	for i := len(s.audit) - 1; i >= 0 && len(results) < filter.Limit; i-- {
+		entry := &models.AuditEntry{}
+		err := json.Unmarshal(s.audit[i], entry)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Matches(entry) {
+			results = append(results, entry)
+		}
+	}
+
+	return results, nil
+}
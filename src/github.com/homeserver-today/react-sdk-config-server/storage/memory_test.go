@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+// testConfigStore checks the behaviour every ConfigStore must have. The store must be empty.
+func testConfigStore(t *testing.T, store ConfigStore) {
+	ctx := context.Background()
+
+	if _, err := store.GetConfig(ctx, "t2bot.io"); err != ErrConfigNotFound {
+		t.Fatalf("expected ErrConfigNotFound for a missing config, got %v", err)
+	}
+
+	// Creating
+	if err := store.UpsertConfig(ctx, "t2bot.io", models.ReactConfig{"brand": "Riot"}, models.ConfigMetadata{}, NoVersion); err != nil {
+		t.Fatalf("failed to insert config: %v", err)
+	}
+	if err := store.UpsertConfig(ctx, "t2bot.io", models.ReactConfig{"brand": "Other"}, models.ConfigMetadata{}, NoVersion); err != ErrVersionMismatch {
+		t.Errorf("expected inserting over a stored config to be a version mismatch, got %v", err)
+	}
+	record, err := store.GetConfig(ctx, "t2bot.io")
+	if err != nil {
+		t.Fatalf("failed to get config: %v", err)
+	}
+	if record.Domain != "t2bot.io" || record.Config["brand"] != "Riot" {
+		t.Errorf("unexpected record %s: %v", record.Domain, record.Config)
+	}
+	if record.Version == NoVersion || record.Version == AnyVersion {
+		t.Fatalf("expected a stored version, got %d", record.Version)
+	}
+	if record.UpdatedTs == 0 {
+		t.Error("expected the updated timestamp to be set")
+	}
+
+	// Changing the record must not change what is stored
+	record.Config["brand"] = "Mutated"
+	if stored, _ := store.GetConfig(ctx, "t2bot.io"); stored.Config["brand"] != "Riot" {
+		t.Errorf("expected the stored config to be unaffected by changes to a record, got %v", stored.Config["brand"])
+	}
+
+	// Updating
+	version := record.Version
+	if err = store.UpsertConfig(ctx, "t2bot.io", models.ReactConfig{"brand": "Stale"}, models.ConfigMetadata{}, version+1); err != ErrVersionMismatch {
+		t.Errorf("expected a version mismatch for the wrong version, got %v", err)
+	}
+	if err = store.UpsertConfig(ctx, "t2bot.io", models.ReactConfig{"brand": "Element"}, models.ConfigMetadata{}, version); err != nil {
+		t.Fatalf("failed to update config at its version: %v", err)
+	}
+	if err = store.UpsertConfig(ctx, "t2bot.io", models.ReactConfig{"brand": "Stale"}, models.ConfigMetadata{}, version); err != ErrVersionMismatch {
+		t.Errorf("expected a version mismatch for a stale version, got %v", err)
+	}
+	record, err = store.GetConfig(ctx, "t2bot.io")
+	if err != nil {
+		t.Fatalf("failed to get config: %v", err)
+	}
+	if record.Config["brand"] != "Element" || record.Version == version {
+		t.Errorf("expected the update to be stored with a new version, got %v at version %d", record.Config["brand"], record.Version)
+	}
+	version = record.Version
+	if err = store.UpsertConfig(ctx, "t2bot.io", models.ReactConfig{"brand": "Riot"}, models.ConfigMetadata{}, AnyVersion); err != nil {
+		t.Fatalf("failed to update config at any version: %v", err)
+	}
+	if record, _ = store.GetConfig(ctx, "t2bot.io"); record.Version == version {
+		t.Error("expected writing at any version to change the version")
+	}
+
+	// Listing
+	for _, domain := range []string{"*.t2bot.io", "example.org", "*.example.org"} {
+		if err = store.UpsertConfig(ctx, domain, models.ReactConfig{"brand": domain}, models.ConfigMetadata{}, AnyVersion); err != nil {
+			t.Fatalf("failed to insert %s: %v", domain, err)
+		}
+	}
+	globs, err := store.ListGlobs(ctx)
+	if err != nil {
+		t.Fatalf("failed to list globs: %v", err)
+	}
+	globSet := make(map[string]bool)
+	for _, glob := range globs {
+		globSet[glob] = true
+	}
+	if !reflect.DeepEqual(globSet, map[string]bool{"*.t2bot.io": true, "*.example.org": true}) {
+		t.Errorf("unexpected globs %v", globs)
+	}
+	records, err := store.ListConfigs(ctx)
+	if err != nil {
+		t.Fatalf("failed to list configs: %v", err)
+	}
+	domains := make([]string, 0, len(records))
+	for _, r := range records {
+		domains = append(domains, r.Domain)
+	}
+	if !reflect.DeepEqual(domains, []string{"*.example.org", "*.t2bot.io", "example.org", "t2bot.io"}) {
+		t.Errorf("expected the configs sorted by domain, got %v", domains)
+	}
+
+	// Deleting
+	record, _ = store.GetConfig(ctx, "t2bot.io")
+	if err = store.DeleteConfig(ctx, "t2bot.io", NoVersion); err != ErrVersionMismatch {
+		t.Errorf("expected deleting with no version to be a version mismatch, got %v", err)
+	}
+	if err = store.DeleteConfig(ctx, "t2bot.io", record.Version+1); err != ErrVersionMismatch {
+		t.Errorf("expected deleting with the wrong version to be a version mismatch, got %v", err)
+	}
+	if err = store.DeleteConfig(ctx, "t2bot.io", record.Version); err != nil {
+		t.Fatalf("failed to delete config at its version: %v", err)
+	}
+	if _, err = store.GetConfig(ctx, "t2bot.io"); err != ErrConfigNotFound {
+		t.Errorf("expected ErrConfigNotFound after deleting, got %v", err)
+	}
+	if err = store.DeleteConfig(ctx, "example.org", AnyVersion); err != nil {
+		t.Fatalf("failed to delete config at any version: %v", err)
+	}
+	if err = store.UpsertConfig(ctx, "example.org", models.ReactConfig{}, models.ConfigMetadata{}, NoVersion); err != nil {
+		t.Errorf("expected inserting a deleted config to work, got %v", err)
+	}
+}
+
+func TestMemoryStoreConfigs(t *testing.T) {
+	testConfigStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreRemovedTs(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	exact := &models.HostMatcher{Type: models.MatcherExact, Patterns: []string{"chat.t2bot.io"}}
+
+	tests := []struct {
+		name    string
+		change  func() (error)
+		removed bool
+	}{
+		{"creating a template", func() (error) {
+			return store.UpsertConfig(ctx, "*.t2bot.io", models.ReactConfig{}, models.ConfigMetadata{}, NoVersion)
+		}, false},
+		{"changing a template's config", func() (error) {
+			return store.UpsertConfig(ctx, "*.t2bot.io", models.ReactConfig{"brand": "Riot"}, models.ConfigMetadata{}, AnyVersion)
+		}, false},
+		{"changing a template's matcher", func() (error) {
+			return store.UpsertConfig(ctx, "*.t2bot.io", models.ReactConfig{}, models.ConfigMetadata{Matcher: exact}, AnyVersion)
+		}, true},
+		{"creating a domain", func() (error) {
+			return store.UpsertConfig(ctx, "t2bot.io", models.ReactConfig{}, models.ConfigMetadata{}, NoVersion)
+		}, false},
+		{"deleting a domain", func() (error) {
+			return store.DeleteConfig(ctx, "t2bot.io", AnyVersion)
+		}, true},
+		{"deleting a missing domain", func() (error) {
+			return store.DeleteConfig(ctx, "t2bot.io", AnyVersion)
+		}, false},
+		{"importing a deletion", func() (error) {
+			return store.ImportConfigs(ctx, nil, []string{"*.t2bot.io"})
+		}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before, _ := store.GetRemovedTs(ctx)
+			time.Sleep(2 * time.Millisecond)
+			if err := test.change(); err != nil {
+				t.Fatalf("failed to make change: %v", err)
+			}
+
+			after, _ := store.GetRemovedTs(ctx)
+			if removed := after != before; removed != test.removed {
+				t.Errorf("expected removed to be %v", test.removed)
+			}
+		})
+	}
+}
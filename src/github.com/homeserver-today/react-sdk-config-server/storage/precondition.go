@@ -0,0 +1,58 @@
+package storage
+
+import "errors"
+
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Precondition describes the state a domain's stored config must be in for a write to happen.
+// The zero value allows every write.
+type Precondition struct {
+	MustExist    bool
+	MustNotExist bool
+	MatchETags   []string // the stored config's ETag must be one of these
+	NoMatchETags []string // the stored config's ETag must not be one of these
+}
+
+func (p *Precondition) isEmpty() (bool) {
+	return p == nil || (!p.MustExist && !p.MustNotExist && len(p.MatchETags) == 0 && len(p.NoMatchETags) == 0)
+}
+
+// check returns the version the stored config must have at the time of the write
+func (p *Precondition) check(record *ConfigRecord) (int64, error) {
+	if p.isEmpty() {
+		return AnyVersion, nil
+	}
+
+	if record == nil {
+		if p.MustExist || len(p.MatchETags) > 0 {
+			return 0, ErrPreconditionFailed
+		}
+		return NoVersion, nil
+	}
+
+	if p.MustNotExist {
+		return 0, ErrPreconditionFailed
+	}
+
+	etag, err := record.ETag()
+	if err != nil {
+		return 0, err
+	}
+	if len(p.MatchETags) > 0 && !containsString(p.MatchETags, etag) {
+		return 0, ErrPreconditionFailed
+	}
+	if containsString(p.NoMatchETags, etag) {
+		return 0, ErrPreconditionFailed
+	}
+
+	return record.Version, nil
+}
+
+func containsString(haystack []string, needle string) (bool) {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"testing"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+func TestPreconditionCheck(t *testing.T) {
+	record := &ConfigRecord{Domain: "t2bot.io", Config: models.ReactConfig{"brand": "Riot"}, Version: 3}
+	etag, err := record.ETag()
+	if err != nil {
+		t.Fatalf("failed to calculate ETag: %v", err)
+	}
+	otherEtag := `"0123456789abcdef0123456789abcdef"`
+
+	tests := []struct {
+		name            string
+		precondition    *Precondition
+		record          *ConfigRecord
+		expectedVersion int64
+		expectedErr     error
+	}{
+		{"nil allows anything", nil, record, AnyVersion, nil},
+		{"empty allows anything", &Precondition{}, nil, AnyVersion, nil},
+		{"matching ETag uses the stored version", &Precondition{MatchETags: []string{etag}}, record, 3, nil},
+		{"any of several ETags may match", &Precondition{MatchETags: []string{otherEtag, etag}}, record, 3, nil},
+		{"different ETag fails", &Precondition{MatchETags: []string{otherEtag}}, record, 0, ErrPreconditionFailed},
+		{"ETag without a stored config fails", &Precondition{MatchETags: []string{etag}}, nil, 0, ErrPreconditionFailed},
+		{"must exist with a stored config", &Precondition{MustExist: true}, record, 3, nil},
+		{"must exist without a stored config fails", &Precondition{MustExist: true}, nil, 0, ErrPreconditionFailed},
+		{"must not exist without a stored config", &Precondition{MustNotExist: true}, nil, NoVersion, nil},
+		{"must not exist with a stored config fails", &Precondition{MustNotExist: true}, record, 0, ErrPreconditionFailed},
+		{"not matching a different ETag", &Precondition{NoMatchETags: []string{otherEtag}}, record, 3, nil},
+		{"not matching the stored ETag fails", &Precondition{NoMatchETags: []string{etag}}, record, 0, ErrPreconditionFailed},
+		{"not matching without a stored config", &Precondition{NoMatchETags: []string{etag}}, nil, NoVersion, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, err := test.precondition.check(test.record)
+			if err != test.expectedErr {
+				t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+			}
+			if err == nil && version != test.expectedVersion {
+				t.Errorf("expected version %d, got %d", test.expectedVersion, version)
+			}
+		})
+	}
+}
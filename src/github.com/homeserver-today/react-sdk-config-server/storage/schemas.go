@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+	"github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+	"github.com/patrickmn/go-cache"
+	"github.com/ryanuber/go-glob"
+)
+
+// Compiled schemas are only cached briefly so that changes made by other instances are noticed quickly
+const schemaCacheExpiration = 1 * time.Minute
+const schemaCleanupInterval = 5 * time.Minute
+
+var schemaCache *cache.Cache
+var schemaCacheSingletonLock = &sync.Once{}
+
+func getSchemaCache() (*cache.Cache) {
+	if schemaCache == nil {
+		schemaCacheSingletonLock.Do(func() {
+			schemaCache = cache.New(schemaCacheExpiration, schemaCleanupInterval)
+		})
+	}
+	return schemaCache
+}
+
+func getSchemaStore() (SchemaStore, error) {
+	schemaStore, ok := GetStore().(SchemaStore)
+	if !ok {
+		return nil, ErrSchemasNotSupported
+	}
+	return schemaStore, nil
+}
+
+// GetSchema gets the named schema, which may be the bundled Element schema
+func GetSchema(ctx context.Context, name string) (*models.StoredSchema, error) {
+	if name == models.ElementSchemaName {
+		return &models.StoredSchema{Name: name, Schema: []byte(models.ElementSchema)}, nil
+	}
+
+	schemaStore, err := getSchemaStore()
+	if err == ErrSchemasNotSupported {
+		return nil, ErrSchemaNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return schemaStore.GetSchema(ctx, name)
+}
+
+// ListSchemas gets the bundled Element schema followed by any stored schemas
+func ListSchemas(ctx context.Context) ([]*models.StoredSchema, error) {
+	results := []*models.StoredSchema{{Name: models.ElementSchemaName, Schema: []byte(models.ElementSchema)}}
+
+	schemaStore, err := getSchemaStore()
+	if err == ErrSchemasNotSupported {
+		return results, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	stored, err := schemaStore.ListSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, stored...), nil
+}
+
+// SetSchema stores the schema under the name, returning a *models.ValidationError if it is not a
+// valid schema or the name is reserved.
+func SetSchema(ctx context.Context, name string, schema []byte) (*models.StoredSchema, error) {
+	if name == models.ElementSchemaName {
+		return nil, &models.ValidationError{Message: "The " + name + " schema is bundled and cannot be changed"}
+	}
+
+	schemaStore, err := getSchemaStore()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = models.CompileSchema(name, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &models.StoredSchema{Name: name, Schema: schema, UpdatedTs: util.NowMillis()}
+	err = schemaStore.UpsertSchema(ctx, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	getSchemaCache().Delete(name)
+	return stored, nil
+}
+
+// DeleteSchema removes the schema, returning a *models.ValidationError if it is bundled or the
+// server is configured to use it.
+func DeleteSchema(ctx context.Context, name string) (error) {
+	if name == models.ElementSchemaName {
+		return &models.ValidationError{Message: "The " + name + " schema is bundled and cannot be deleted"}
+	}
+	if isConfiguredSchema(name) {
+		return &models.ValidationError{Message: "The " + name + " schema is used by the server's configuration"}
+	}
+
+	schemaStore, err := getSchemaStore()
+	if err != nil {
+		return err
+	}
+
+	err = schemaStore.DeleteSchema(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	getSchemaCache().Delete(name)
+	return nil
+}
+
+// SchemaNameForDomain gets the name of the schema which the domain's configs are validated against,
+// or an empty string if there isn't one. The first domain pattern which matches is used.
+func SchemaNameForDomain(domain string) (string) {
+	validationConfig := config.Get().Validation
+	for _, d := range validationConfig.DomainSchemas {
+		if glob.Glob(d.Domain, domain) {
+			return d.Schema
+		}
+	}
+	return validationConfig.Schema
+}
+
+func isConfiguredSchema(name string) (bool) {
+	validationConfig := config.Get().Validation
+	if validationConfig.Schema == name {
+		return true
+	}
+	for _, d := range validationConfig.DomainSchemas {
+		if d.Schema == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getCompiledSchema gets the named schema ready for validating configs
+func getCompiledSchema(ctx context.Context, name string) (*models.ConfigSchema, error) {
+	if cached, found := getSchemaCache().Get(name); found {
+		return cached.(*models.ConfigSchema), nil
+	}
+
+	stored, err := GetSchema(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := models.CompileSchema(stored.Name, stored.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	getSchemaCache().Set(name, compiled, cache.DefaultExpiration)
+	return compiled, nil
+}
+
+// ValidateAgainstSchema checks the config against the named schema, returning a
+// *models.SchemaValidationError if it does not match.
+func ValidateAgainstSchema(ctx context.Context, schemaName string, domain string, config models.ReactConfig) (error) {
+	schema, err := getCompiledSchema(ctx, schemaName)
+	if err != nil {
+		return err
+	}
+	return schema.Validate(domain, config)
+}
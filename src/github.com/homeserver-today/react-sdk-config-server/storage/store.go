@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrConfigNotFound = errors.New("config not found")
+var ErrRevisionNotFound = errors.New("revision not found")
+var ErrRevisionsNotSupported = errors.New("storage backend does not support revision history")
+var ErrVersionMismatch = errors.New("stored config version does not match")
+var ErrTokenNotFound = errors.New("token not found")
+var ErrTokenExists = errors.New("token already exists")
+var ErrTokensNotSupported = errors.New("storage backend does not support API tokens")
+var ErrImportNotSupported = errors.New("storage backend does not support importing configs")
+var ErrSchemaNotFound = errors.New("schema not found")
+var ErrSchemasNotSupported = errors.New("storage backend does not support schemas")
+var ErrAuditNotSupported = errors.New("storage backend does not support the audit log")
+
+// Versions given to writes to control when they may happen. Any other value requires the stored
+// config to be at exactly that version.
+const AnyVersion int64 = -1 // the write always happens
+const NoVersion int64 = 0   // the write only happens if there is no stored config
+
+// ConfigRecord is a config as it is stored. The version changes every time the config is written.
+type ConfigRecord struct {
+	Domain    string
+	Config    models.ReactConfig
+	Metadata  models.ConfigMetadata
+	Version   int64
+	UpdatedTs int64
+}
+
+// ETag is a strong entity tag for the stored config and its metadata
+func (r *ConfigRecord) ETag() (string, error) {
+	combined := models.ReactConfig{"config": r.Config, "metadata": r.Metadata}
+	return combined.ETag()
+}
+
+// ConfigStore is the persistence layer behind the config cache. Implementations must return
+// ErrConfigNotFound when a domain has no stored config, and ErrVersionMismatch when a write's
+// expected version does not match what is stored.
+type ConfigStore interface {
+	GetConfig(ctx context.Context, domain string) (*ConfigRecord, error)
+	UpsertConfig(ctx context.Context, domain string, config models.ReactConfig, metadata models.ConfigMetadata, expectedVersion int64) (error)
+	DeleteConfig(ctx context.Context, domain string, expectedVersion int64) (error)
+	ListGlobs(ctx context.Context) ([]string, error)
+	ListConfigs(ctx context.Context) ([]*ConfigRecord, error)
+}
+
+// RevisionStore is implemented by backends which are able to keep a history of changes to configs.
+// InsertRevision is expected to assign the next revision number for the domain to the revision.
+type RevisionStore interface {
+	InsertRevision(ctx context.Context, revision *models.ConfigRevision) (error)
+	ListRevisions(ctx context.Context, domain string) ([]*models.ConfigRevision, error)
+	GetRevision(ctx context.Context, domain string, revision int) (*models.ConfigRevision, error)
+}
+
+// ImportStore is implemented by backends which are able to change many configs at once. Either all
+// of the changes are made, or none of them are.
+type ImportStore interface {
+	ImportConfigs(ctx context.Context, upserts []*ConfigRecord, deletes []string) (error)
+}
+
+// TokenStore is implemented by backends which are able to store API tokens
+type TokenStore interface {
+	InsertToken(ctx context.Context, token *models.StoredApiToken) (error)
+	GetTokenById(ctx context.Context, id string) (*models.StoredApiToken, error)
+	GetTokenByName(ctx context.Context, name string) (*models.StoredApiToken, error)
+	ListTokens(ctx context.Context) ([]*models.StoredApiToken, error)
+	UpdateToken(ctx context.Context, token *models.StoredApiToken) (error)
+}
+
+// SchemaStore is implemented by backends which are able to store JSON Schemas for validating configs
+type SchemaStore interface {
+	UpsertSchema(ctx context.Context, schema *models.StoredSchema) (error)
+	GetSchema(ctx context.Context, name string) (*models.StoredSchema, error)
+	ListSchemas(ctx context.Context) ([]*models.StoredSchema, error)
+	DeleteSchema(ctx context.Context, name string) (error)
+}
+
+// AuditStore is implemented by backends which are able to keep an audit log of changes. The audited
+// writes behave like their ConfigStore and ImportStore counterparts, but also record the entries for
+// the changes, which are only kept if the changes are made. The store assigns entries their IDs, and
+// ListAuditEntries returns the newest entries first.
+type AuditStore interface {
+	UpsertAuditedConfig(ctx context.Context, domain string, config models.ReactConfig, metadata models.ConfigMetadata, expectedVersion int64, entry *models.AuditEntry) (error)
+	DeleteAuditedConfig(ctx context.Context, domain string, expectedVersion int64, entry *models.AuditEntry) (error)
+	ImportAuditedConfigs(ctx context.Context, upserts []*ConfigRecord, deletes []string, entries []*models.AuditEntry) (error)
+	ListAuditEntries(ctx context.Context, filter *models.AuditFilter) ([]*models.AuditEntry, error)
+}
+
+// RemovalStore is implemented by backends which know when a stored config last stopped applying to
+// domains, such as by being deleted or by a template's matcher changing. The configs calculated for
+// domains are treated as having changed then too, as what is still stored for them can be older than
+// what was served before.
+type RemovalStore interface {
+	GetRemovedTs(ctx context.Context) (int64, error)
+}
+
+// matcherChanged determines if a glob's matcher is different in the new metadata
+func matcherChanged(before models.ConfigMetadata, after models.ConfigMetadata) (bool) {
+	return !reflect.DeepEqual(before.Matcher, after.Matcher)
+}
+
+var storeInstance ConfigStore
+var singletonStoreLock = &sync.Once{}
+
+func GetStore() (ConfigStore) {
+	if storeInstance == nil {
+		singletonStoreLock.Do(func() {
+			err := OpenStore()
+			if err != nil {
+				panic(err)
+			}
+		})
+	}
+	return storeInstance
+}
+
+func OpenStore() (error) {
+	dbConfig := config.Get().Database
+
+	var store ConfigStore
+	var err error
+	switch dbConfig.Driver {
+	case config.DriverMemory:
+		logrus.Warn("Using the in-memory storage backend - configs will be lost when the server stops")
+		store = NewMemoryStore()
+		break
+	case config.DriverDirectory:
+		store, err = OpenDirectoryStore(dbConfig.Directory)
+		break
+	case config.DriverSqlite:
+		store, err = OpenDatabase(config.DriverSqlite, dbConfig.Sqlite)
+		break
+	case config.DriverPostgres, "":
+		store, err = OpenDatabase(config.DriverPostgres, dbConfig.Postgres)
+		break
+	default:
+		return errors.New("unknown database driver: " + dbConfig.Driver)
+	}
+	if err != nil {
+		return err
+	}
+
+	storeInstance = store
+	if db, ok := store.(*Database); ok {
+		// Only databases from before configs had a metadata column can have metadata in their configs.
+		// Directories keep metadata in the configs anyway, and memory starts empty.
+		return db.migrateLegacyMetadataOnce()
+	}
+	return nil
+}
+
+// migrateLegacyMetadata moves metadata which is stored in configs (as hstoday.* keys) to where the
+// store keeps metadata.
+func migrateLegacyMetadata(store ConfigStore) (error) {
+	ctx := context.Background()
+	records, err := store.ListConfigs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		config, found, err := models.ExtractMetadata(record.Config, &record.Metadata)
+		if !found {
+			continue
+		}
+		if err != nil {
+			logrus.Warn("Not migrating metadata for "+record.Domain+": ", err)
+			continue
+		}
+
+		logrus.Info("Migrating metadata out of the config for " + record.Domain)
+		err = store.UpsertConfig(ctx, record.Domain, config, record.Metadata, record.Version)
+		if err == ErrVersionMismatch {
+			logrus.Warn("Config for " + record.Domain + " changed while migrating metadata - skipping")
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+	"github.com/patrickmn/go-cache"
+)
+
+// Stored tokens are only cached briefly so that changes made by other instances are noticed quickly
+const tokenCacheExpiration = 1 * time.Minute
+const tokenCleanupInterval = 5 * time.Minute
+
+var tokenCache *cache.Cache
+var tokenCacheSingletonLock = &sync.Once{}
+
+func getTokenCache() (*cache.Cache) {
+	if tokenCache == nil {
+		tokenCacheSingletonLock.Do(func() {
+			tokenCache = cache.New(tokenCacheExpiration, tokenCleanupInterval)
+		})
+	}
+	return tokenCache
+}
+
+func getTokenStore() (TokenStore, error) {
+	tokenStore, ok := GetStore().(TokenStore)
+	if !ok {
+		return nil, ErrTokensNotSupported
+	}
+	return tokenStore, nil
+}
+
+// CreateApiToken stores a new token, returning the stored token and its plaintext. The plaintext
+// is not kept anywhere and cannot be retrieved again.
+func CreateApiToken(ctx context.Context, token models.ApiToken, expiresTs int64) (*models.StoredApiToken, string, error) {
+	tokenStore, err := getTokenStore()
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := randomHex(12)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+	salt, err := randomHex(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stored := &models.StoredApiToken{
+		ApiToken:  token,
+		Id:        id,
+		Salt:      salt,
+		Hash:      hashSecret(salt, secret),
+		CreatedTs: util.NowMillis(),
+		ExpiresTs: expiresTs,
+		Revoked:   false,
+	}
+	err = tokenStore.InsertToken(ctx, stored)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return stored, id + "." + secret, nil
+}
+
+func ListApiTokens(ctx context.Context) ([]*models.StoredApiToken, error) {
+	tokenStore, err := getTokenStore()
+	if err != nil {
+		return nil, err
+	}
+	return tokenStore.ListTokens(ctx)
+}
+
+func RevokeApiToken(ctx context.Context, name string) (*models.StoredApiToken, error) {
+	return updateApiToken(ctx, name, func(token *models.StoredApiToken) {
+		token.Revoked = true
+	})
+}
+
+// ExpireApiToken sets when the token stops working. A time in the past expires it immediately.
+func ExpireApiToken(ctx context.Context, name string, expiresTs int64) (*models.StoredApiToken, error) {
+	return updateApiToken(ctx, name, func(token *models.StoredApiToken) {
+		token.ExpiresTs = expiresTs
+	})
+}
+
+func updateApiToken(ctx context.Context, name string, update func(token *models.StoredApiToken)) (*models.StoredApiToken, error) {
+	tokenStore, err := getTokenStore()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := tokenStore.GetTokenByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	update(token)
+	err = tokenStore.UpdateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	getTokenCache().Delete(token.Id)
+	return token, nil
+}
+
+// FindApiToken returns the stored token matching the plaintext, or nil if there isn't a usable one
+func FindApiToken(ctx context.Context, plaintext string) (*models.ApiToken, error) {
+	tokenStore, err := getTokenStore()
+	if err == ErrTokensNotSupported {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(plaintext, ".", 2)
+	if len(parts) != 2 {
+		return nil, nil
+	}
+	id := parts[0]
+	secret := parts[1]
+
+	var token *models.StoredApiToken
+	if cached, found := getTokenCache().Get(id); found {
+		token = cached.(*models.StoredApiToken)
+	} else {
+		token, err = tokenStore.GetTokenById(ctx, id)
+		if err == ErrTokenNotFound {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		getTokenCache().Set(id, token, cache.DefaultExpiration)
+	}
+
+	expectedHash := []byte(token.Hash)
+	if subtle.ConstantTimeCompare([]byte(hashSecret(token.Salt, secret)), expectedHash) != 1 {
+		return nil, nil
+	}
+	if !token.IsUsable(util.NowMillis()) {
+		return nil, nil
+	}
+
+	apiToken := token.ApiToken
+	return &apiToken, nil
+}
+
+func hashSecret(salt string, secret string) (string) {
+	hash := sha256.Sum256([]byte(salt + secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(numBytes int) (string, error) {
+	b := make([]byte, numBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+)
+
+func TestFindApiToken(t *testing.T) {
+	storeInstance = NewMemoryStore()
+	ctx := context.Background()
+
+	create := func(name string) (string) {
+		_, plaintext, err := CreateApiToken(ctx, models.ApiToken{Name: name, Domains: []string{"*"}, Permissions: []string{models.PermissionRead}}, 0)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+		return plaintext
+	}
+	valid := create("valid")
+	revoked := create("revoked")
+	expired := create("expired")
+	expiring := create("expiring")
+
+	// Look the tokens up first so that changing them has to get past the token cache
+	for _, plaintext := range []string{revoked, expired} {
+		if token, err := FindApiToken(ctx, plaintext); err != nil || token == nil {
+			t.Fatalf("expected token to be usable before changing it, got %v, %v", token, err)
+		}
+	}
+	if _, err := RevokeApiToken(ctx, "revoked"); err != nil {
+		t.Fatalf("failed to revoke token: %v", err)
+	}
+	if _, err := ExpireApiToken(ctx, "expired", util.NowMillis()-1000); err != nil {
+		t.Fatalf("failed to expire token: %v", err)
+	}
+	if _, err := ExpireApiToken(ctx, "expiring", util.NowMillis()+60000); err != nil {
+		t.Fatalf("failed to expire token: %v", err)
+	}
+
+	id := strings.SplitN(valid, ".", 2)[0]
+	tests := []struct {
+		name      string
+		plaintext string
+		expected  string
+	}{
+		{"valid", valid, "valid"},
+		{"expires later", expiring, "expiring"},
+		{"wrong secret", id + ".0123456789abcdef", ""},
+		{"secret from another token", id + "." + strings.SplitN(expiring, ".", 2)[1], ""},
+		{"unknown ID", "000000000000000000000000." + strings.SplitN(valid, ".", 2)[1], ""},
+		{"no separator", id, ""},
+		{"empty", "", ""},
+		{"revoked", revoked, ""},
+		{"expired", expired, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := FindApiToken(ctx, test.plaintext)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expected == "" && token != nil {
+				t.Errorf("expected no token, got %s", token.Name)
+			} else if test.expected != "" && (token == nil || token.Name != test.expected) {
+				t.Errorf("expected token %s, got %v", test.expected, token)
+			}
+		})
+	}
+}
+
+func TestHashSecret(t *testing.T) {
+	if hashSecret("salt", "secret") != hashSecret("salt", "secret") {
+		t.Error("expected hashing to be repeatable")
+	}
+	if hashSecret("salt", "secret") == hashSecret("pepper", "secret") {
+		t.Error("expected the salt to change the hash")
+	}
+	if hashSecret("salt", "secret") == hashSecret("salt", "other") {
+		t.Error("expected the secret to change the hash")
+	}
+}
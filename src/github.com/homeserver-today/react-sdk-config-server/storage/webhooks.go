@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+	"github.com/homeserver-today/react-sdk-config-server/webhooks"
+)
+
+// changeNotifier tells webhooks about changes to configs. It is created before the changes are made
+// so that domains which stop using a template because of a change are still told about it.
+type changeNotifier struct {
+	cache *configCache
+
+	// The domains being changed
+	changing []string
+
+	// Everything below is only known if templates are changing. The known domains are those with a
+	// stored config and those in the cache, along with what was cached for them before the changes.
+	records     map[string]*ConfigRecord
+	domains     map[string]*ResolvedConfig
+	usageBefore map[string]map[string]bool
+	usageAfter  map[string]map[string]bool
+}
+
+// newChangeNotifier prepares to tell webhooks about changes to the domains, returning nil if there
+// are no webhooks.
+func (c *configCache) newChangeNotifier(domains []string) (*changeNotifier) {
+	if !webhooks.GetDispatcher().HasHooks() {
+		return nil
+	}
+	return c.trackChanges(domains)
+}
+
+// trackChanges records which known domains use the templates among the domains before they change,
+// so that the domains affected by the changes can be found afterwards.
+func (c *configCache) trackChanges(domains []string) (*changeNotifier) {
+	n := &changeNotifier{cache: c, changing: domains}
+	templates := make([]string, 0)
+	for _, domain := range domains {
+		if models.IsTemplate(domain) {
+			templates = append(templates, domain)
+		}
+	}
+	if len(templates) == 0 {
+		return n
+	}
+
+	n.records = make(map[string]*ConfigRecord)
+	n.domains = make(map[string]*ResolvedConfig)
+	records, err := GetStore().ListConfigs(c.ctx)
+	if err != nil {
+		c.log.Error("Failed to list configs to find domains affected by a template: ", err)
+	} else {
+		for _, record := range records {
+			n.records[record.Domain] = record
+			if !models.IsTemplate(record.Domain) {
+				n.domains[record.Domain] = nil
+			}
+		}
+	}
+	for key, item := range c.cache.Items() {
+		if strings.HasPrefix(key, domainPrefix) {
+			n.domains[strings.TrimPrefix(key, domainPrefix)] = item.(*ResolvedConfig)
+		}
+	}
+
+	n.usageBefore = n.templateUsage(templates, true)
+	return n
+}
+
+// notify tells webhooks about a change which has been made. Anything cached for the domain must
+// already have been invalidated.
+func (n *changeNotifier) notify(domain string, action string, diff []*models.AuditChange) {
+	if n == nil {
+		return
+	}
+
+	affected := []string{domain}
+	if models.IsTemplate(domain) {
+		if n.usageAfter == nil {
+			n.refreshRecords()
+			n.usageAfter = n.templateUsage(n.changing, false)
+		}
+		affected = n.affectedDomains(domain)
+	}
+
+	webhooks.GetDispatcher().Dispatch(&models.ConfigChange{
+		Domain:          domain,
+		Action:          action,
+		Actor:           n.cache.actor,
+		ChangedTs:       util.NowMillis(),
+		Diff:            diff,
+		AffectedDomains: affected,
+	}, n.cache.log)
+}
+
+// assumeChanges treats the changes as though they have already been stored, where each changing
+// domain maps to its new record (or nil if it is being deleted). This allows the affected domains to
+// be found before the changes are made.
+func (n *changeNotifier) assumeChanges(changes map[string]*ConfigRecord) {
+	for domain, record := range changes {
+		n.setRecord(domain, record)
+	}
+	n.usageAfter = n.templateUsage(n.changing, false)
+}
+
+// affectedDomains gets the known domains which used the template before the changes, or use it after
+// them, sorted. The usage after the changes must already be known.
+func (n *changeNotifier) affectedDomains(template string) ([]string) {
+	affected := make([]string, 0)
+	for d := range n.usageBefore[template] {
+		affected = append(affected, d)
+	}
+	for d := range n.usageAfter[template] {
+		if !n.usageBefore[template][d] {
+			affected = append(affected, d)
+		}
+	}
+	sort.Strings(affected)
+	return affected
+}
+
+// refreshRecords replaces the stored configs of the changed domains with what is stored now
+func (n *changeNotifier) refreshRecords() {
+	for _, domain := range n.changing {
+		record, err := GetStore().GetConfig(n.cache.ctx, domain)
+		if err == ErrConfigNotFound {
+			n.setRecord(domain, nil)
+		} else if err != nil {
+			n.cache.log.Error("Failed to get the config for "+domain+" to find the domains it affects: ", err)
+		} else {
+			n.setRecord(domain, record)
+		}
+	}
+}
+
+// setRecord replaces the stored config of the domain, where nil means it has been deleted
+func (n *changeNotifier) setRecord(domain string, record *ConfigRecord) {
+	if record == nil {
+		delete(n.records, domain)
+		delete(n.domains, domain)
+		return
+	}
+
+	n.records[domain] = record
+	if !models.IsTemplate(domain) {
+		n.domains[domain] = nil
+	}
+}
+
+// templateUsage maps each template to the known domains which use it. A domain uses a template if the
+// template (or a template which extends it) matches the domain or is extended by the domain's stored
+// config, or if the domain's cached config was calculated with it. This is the same reasoning used to
+// invalidate the cache, so doesn't need to calculate every domain's config.
+func (n *changeNotifier) templateUsage(templates []string, useCache bool) (map[string]map[string]bool) {
+	usage := make(map[string]map[string]bool)
+	for _, template := range templates {
+		if !models.IsTemplate(template) {
+			continue
+		}
+
+		using := n.templateUsers(template)
+		matchers := make([]*models.CompiledMatcher, 0)
+		for user := range using {
+			if models.IsNamedTemplate(user) {
+				continue
+			}
+			matcher, err := models.CompileTemplateMatcher(user, n.records[user].Metadata.Matcher)
+			if err != nil {
+				// Matchers are checked when they are set, so this should only happen if the config was edited on disk
+				continue
+			}
+			matchers = append(matchers, matcher)
+		}
+
+		usage[template] = make(map[string]bool)
+		for domain, resolved := range n.domains {
+			used := useCache && resolved != nil && resolved.templates[template]
+			if record, ok := n.records[domain]; ok {
+				for _, parent := range record.Metadata.Extends {
+					used = used || using[parent]
+				}
+			}
+			for _, matcher := range matchers {
+				if _, matched := matcher.Match(domain); matched {
+					used = true
+					break
+				}
+			}
+			if used {
+				usage[template][domain] = true
+			}
+		}
+	}
+	return usage
+}
+
+// templateUsers gets the stored template along with every stored template which extends it, directly
+// or through other templates.
+func (n *changeNotifier) templateUsers(template string) (map[string]bool) {
+	using := make(map[string]bool)
+	if _, ok := n.records[template]; ok {
+		using[template] = true
+	}
+
+	for added := true; added; {
+		added = false
+		for name, record := range n.records {
+			if using[name] || !models.IsTemplate(name) {
+				continue
+			}
+			for _, parent := range record.Metadata.Extends {
+				if using[parent] {
+					using[name] = true
+					added = true
+					break
+				}
+			}
+		}
+	}
+	return using
+}
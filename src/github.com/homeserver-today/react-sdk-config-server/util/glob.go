@@ -0,0 +1,30 @@
+package util
+
+import "strings"
+
+// GlobCaptures matches the subject against a pattern where * matches any text, returning the text
+// matched by each * in order.
+func GlobCaptures(pattern string, subject string) ([]string, bool) {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return []string{}, pattern == subject
+	}
+
+	first := parts[0]
+	last := parts[len(parts)-1]
+	if len(subject) < len(first)+len(last) || !strings.HasPrefix(subject, first) || !strings.HasSuffix(subject, last) {
+		return nil, false
+	}
+	rest := subject[len(first) : len(subject)-len(last)]
+
+	captures := make([]string, 0, len(parts)-1)
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return nil, false
+		}
+		captures = append(captures, rest[:idx])
+		rest = rest[idx+len(part):]
+	}
+	return append(captures, rest), true
+}
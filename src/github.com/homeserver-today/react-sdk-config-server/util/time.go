@@ -0,0 +1,12 @@
+package util
+
+import "time"
+
+// NowMillis is the current time as milliseconds since the Unix epoch
+func NowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func ToMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
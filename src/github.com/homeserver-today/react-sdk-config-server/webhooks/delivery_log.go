@@ -0,0 +1,62 @@
+package webhooks
+
+import (
+	"sync"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+)
+
+// deliveryLog remembers the most recent deliveries. Deliveries are copied in and out so that they
+// can be updated while being read.
+type deliveryLog struct {
+	deliveries []*models.WebhookDelivery
+	max        int
+	lock       *sync.RWMutex
+}
+
+func newDeliveryLog(max int) (*deliveryLog) {
+	if max < 0 {
+		max = 0
+	}
+	return &deliveryLog{
+		deliveries: make([]*models.WebhookDelivery, 0),
+		max:        max,
+		lock:       &sync.RWMutex{},
+	}
+}
+
+func (l *deliveryLog) add(delivery *models.WebhookDelivery) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	stored := *delivery
+	l.deliveries = append(l.deliveries, &stored)
+	if len(l.deliveries) > l.max {
+		l.deliveries = l.deliveries[len(l.deliveries)-l.max:]
+	}
+}
+
+// update changes the delivery if it is still remembered
+func (l *deliveryLog) update(id string, update func(delivery *models.WebhookDelivery)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for i := len(l.deliveries) - 1; i >= 0; i-- {
+		if l.deliveries[i].Id == id {
+			update(l.deliveries[i])
+			return
+		}
+	}
+}
+
+// list gets the deliveries, newest first
+func (l *deliveryLog) list() ([]*models.WebhookDelivery) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	results := make([]*models.WebhookDelivery, 0, len(l.deliveries))
+	for i := len(l.deliveries) - 1; i >= 0; i-- {
+		delivery := *l.deliveries[i]
+		results = append(results, &delivery)
+	}
+	return results
+}
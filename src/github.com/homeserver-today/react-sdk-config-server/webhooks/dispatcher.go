@@ -0,0 +1,308 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/homeserver-today/react-sdk-config-server/util"
+	"github.com/ryanuber/go-glob"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers sent with every delivery. The signature is the hex encoded HMAC-SHA256 of the body, using
+// the webhook's secret as the key, prefixed with "sha256=".
+const HeaderEvent = "X-Config-Server-Event"
+const HeaderDelivery = "X-Config-Server-Delivery"
+const HeaderSignature = "X-Config-Server-Signature"
+
+// Dispatcher sends config changes to webhooks in the background, retrying failed deliveries with
+// exponential backoff. Deliveries are queued for a fixed number of workers to send.
+type Dispatcher struct {
+	hooks       []*config.WebhookConfig
+	client      *http.Client
+	maxAttempts int
+	retryDelay  time.Duration
+	deliveries  *deliveryLog
+	queue       chan *queuedDelivery
+
+	// Held while queueing deliveries so that the queue isn't closed underneath them
+	queueLock *sync.RWMutex
+	closed    bool
+	working   *sync.WaitGroup
+}
+
+// queuedDelivery is a delivery waiting for a worker
+type queuedDelivery struct {
+	hook *config.WebhookConfig
+	id   string
+	body []byte
+	log  *logrus.Entry
+}
+
+var dispatcherInstance *Dispatcher
+var dispatcherSingletonLock = &sync.Once{}
+
+// GetDispatcher gets the dispatcher for the webhooks in the server's config
+func GetDispatcher() (*Dispatcher) {
+	if dispatcherInstance == nil {
+		dispatcherSingletonLock.Do(func() {
+			webhooksConfig := config.Get().Webhooks
+			client := &http.Client{Timeout: time.Duration(webhooksConfig.TimeoutMs) * time.Millisecond}
+			dispatcherInstance = NewDispatcher(webhooksConfig.Hooks, client, webhooksConfig.MaxAttempts, time.Duration(webhooksConfig.RetryDelayMs)*time.Millisecond, webhooksConfig.DeliveryLogMax, webhooksConfig.Workers, webhooksConfig.QueueSize)
+		})
+	}
+	return dispatcherInstance
+}
+
+// NewDispatcher creates a dispatcher for the webhooks. A delivery is attempted up to maxAttempts
+// times, waiting retryDelay after the first failure and twice as long after each failure after that.
+// The most recent deliveries (up to maxDeliveries) are remembered. Up to queueSize deliveries wait
+// for one of the workers to send them.
+func NewDispatcher(hooks []*config.WebhookConfig, client *http.Client, maxAttempts int, retryDelay time.Duration, maxDeliveries int, workers int, queueSize int) (*Dispatcher) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	d := &Dispatcher{
+		hooks:       hooks,
+		client:      client,
+		maxAttempts: maxAttempts,
+		retryDelay:  retryDelay,
+		deliveries:  newDeliveryLog(maxDeliveries),
+		queue:       make(chan *queuedDelivery, queueSize),
+		queueLock:   &sync.RWMutex{},
+		working:     &sync.WaitGroup{},
+	}
+	if d.HasHooks() {
+		for i := 0; i < workers; i++ {
+			d.working.Add(1)
+			go d.work()
+		}
+	}
+	return d
+}
+
+// Close stops accepting deliveries, then waits for the queued deliveries to be sent (or given up on).
+// Processes which exit soon after making changes must close the dispatcher first, or the changes may
+// never be delivered.
+func (d *Dispatcher) Close() {
+	d.queueLock.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.queueLock.Unlock()
+
+	d.working.Wait()
+}
+
+func (d *Dispatcher) HasHooks() (bool) {
+	return len(d.hooks) > 0
+}
+
+// Dispatch starts delivering the change to every webhook interested in it. A webhook is interested
+// if the changed domain or any of the affected domains match its domains, and is only told about the
+// affected domains which match. Deliveries are dropped if the queue is full, or the dispatcher has
+// been closed.
+func (d *Dispatcher) Dispatch(change *models.ConfigChange, log *logrus.Entry) {
+	for _, hook := range d.hooks {
+		affected, interested := filterDomains(hook, change)
+		if !interested {
+			continue
+		}
+
+		id, err := randomHex(16)
+		if err != nil {
+			log.Error("Failed to create webhook delivery ID: ", err)
+			continue
+		}
+
+		payload := &models.WebhookPayload{Id: id, Event: models.WebhookEventConfigChanged, ConfigChange: *change}
+		payload.AffectedDomains = affected
+		body, err := json.Marshal(payload)
+		if err != nil {
+			log.Error("Failed to create webhook payload: ", err)
+			continue
+		}
+
+		nowTs := util.NowMillis()
+		delivery := &models.WebhookDelivery{
+			Id:        id,
+			Url:       hook.Url,
+			Domain:    change.Domain,
+			Action:    change.Action,
+			Status:    models.DeliveryPending,
+			CreatedTs: nowTs,
+			UpdatedTs: nowTs,
+		}
+		d.deliveries.add(delivery)
+
+		hookLog := log.WithFields(logrus.Fields{
+			"webhook":  hook.Url,
+			"delivery": id,
+		})
+		err = d.enqueue(&queuedDelivery{hook: hook, id: id, body: body, log: hookLog})
+		if err != nil {
+			hookLog.Error("Dropping webhook delivery: ", err)
+			d.deliveries.update(id, func(stored *models.WebhookDelivery) {
+				stored.Status = models.DeliveryFailed
+				stored.Error = err.Error()
+				stored.UpdatedTs = util.NowMillis()
+			})
+		}
+	}
+}
+
+var errQueueFull = errors.New("too many deliveries were queued")
+var errDispatcherClosed = errors.New("the dispatcher was closed")
+
+// enqueue adds the delivery to the queue, unless the queue is full or has been closed
+func (d *Dispatcher) enqueue(queued *queuedDelivery) (error) {
+	d.queueLock.RLock()
+	defer d.queueLock.RUnlock()
+
+	if d.closed {
+		return errDispatcherClosed
+	}
+	select {
+	case d.queue <- queued:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+// work sends queued deliveries one at a time, until the dispatcher is closed and the queue is empty
+func (d *Dispatcher) work() {
+	defer d.working.Done()
+	for queued := range d.queue {
+		d.deliver(queued.hook, queued.id, queued.body, queued.log)
+	}
+}
+
+// Deliveries gets the remembered deliveries, newest first
+func (d *Dispatcher) Deliveries() ([]*models.WebhookDelivery) {
+	return d.deliveries.list()
+}
+
+func (d *Dispatcher) deliver(hook *config.WebhookConfig, deliveryId string, body []byte, log *logrus.Entry) {
+	delay := d.retryDelay
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		statusCode, err := d.send(hook, deliveryId, body)
+
+		status := models.DeliveryPending
+		if err == nil {
+			status = models.DeliveryDelivered
+		} else if attempt == d.maxAttempts {
+			status = models.DeliveryFailed
+		}
+		d.deliveries.update(deliveryId, func(stored *models.WebhookDelivery) {
+			stored.Attempts = attempt
+			stored.Status = status
+			stored.StatusCode = statusCode
+			stored.Error = ""
+			if err != nil {
+				stored.Error = err.Error()
+			}
+			stored.UpdatedTs = util.NowMillis()
+		})
+
+		if err == nil {
+			log.Info("Delivered webhook (attempt " + strconv.Itoa(attempt) + ")")
+			return
+		}
+		if status == models.DeliveryFailed {
+			log.Error("Giving up on webhook after "+strconv.Itoa(attempt)+" attempts: ", err)
+			return
+		}
+
+		log.Warn("Failed to deliver webhook (attempt "+strconv.Itoa(attempt)+"), retrying in ", delay, ": ", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// send makes a single attempt to deliver the body, returning the status code the receiver replied with
+func (d *Dispatcher) send(hook *config.WebhookConfig, deliveryId string, body []byte) (int, error) {
+	req, err := http.NewRequest("POST", hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "react-sdk-config-server")
+	req.Header.Set(HeaderEvent, models.WebhookEventConfigChanged)
+	req.Header.Set(HeaderDelivery, deliveryId)
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, body))
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, errors.New("receiver replied with " + res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// Sign calculates the signature header for a body sent to a webhook with the given secret
+func Sign(secret string, body []byte) (string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// filterDomains gets the affected domains which the webhook is interested in, and whether it is
+// interested in the change at all. Webhooks without domains are interested in everything.
+func filterDomains(hook *config.WebhookConfig, change *models.ConfigChange) ([]string, bool) {
+	if len(hook.Domains) == 0 {
+		return change.AffectedDomains, true
+	}
+
+	matches := func(domain string) (bool) {
+		for _, pattern := range hook.Domains {
+			if glob.Glob(pattern, domain) {
+				return true
+			}
+		}
+		return false
+	}
+
+	affected := make([]string, 0)
+	for _, domain := range change.AffectedDomains {
+		if matches(domain) {
+			affected = append(affected, domain)
+		}
+	}
+	return affected, len(affected) > 0 || matches(change.Domain)
+}
+
+func randomHex(numBytes int) (string, error) {
+	b := make([]byte, numBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,299 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"github.com/homeserver-today/react-sdk-config-server/config"
+	"github.com/homeserver-today/react-sdk-config-server/models"
+	"github.com/sirupsen/logrus"
+)
+
+// receivedRequest is a request made to the test receiver
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver starts a receiver which replies with the given status codes in turn, then 200
+func newReceiver(t *testing.T, statusCodes ...int) (*httptest.Server, func() ([]*receivedRequest)) {
+	lock := &sync.Mutex{}
+	received := make([]*receivedRequest, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %v", err)
+		}
+
+		lock.Lock()
+		received = append(received, &receivedRequest{header: r.Header, body: body})
+		statusCode := http.StatusOK
+		if len(received) <= len(statusCodes) {
+			statusCode = statusCodes[len(received)-1]
+		}
+		lock.Unlock()
+
+		w.WriteHeader(statusCode)
+	}))
+
+	return server, func() ([]*receivedRequest) {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]*receivedRequest{}, received...)
+	}
+}
+
+// waitForDelivery waits for the dispatcher's newest delivery to stop being pending
+func waitForDelivery(t *testing.T, d *Dispatcher) (*models.WebhookDelivery) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries := d.Deliveries()
+		if len(deliveries) > 0 && deliveries[0].Status != models.DeliveryPending {
+			return deliveries[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("delivery did not finish")
+	return nil
+}
+
+func newTestDispatcher(hook *config.WebhookConfig, maxAttempts int) (*Dispatcher) {
+	return NewDispatcher([]*config.WebhookConfig{hook}, &http.Client{Timeout: 5 * time.Second}, maxAttempts, time.Millisecond, 10, 1, 10)
+}
+
+func newTestChange() (*models.ConfigChange) {
+	return &models.ConfigChange{
+		Domain:          "*.t2bot.io",
+		Action:          models.AuditActionPatch,
+		Actor:           "t2bot",
+		ChangedTs:       1523059200000,
+		AffectedDomains: []string{"chat.t2bot.io", "example.org"},
+	}
+}
+
+func TestDispatchSignsBody(t *testing.T) {
+	server, received := newReceiver(t)
+	defer server.Close()
+
+	d := newTestDispatcher(&config.WebhookConfig{Url: server.URL, Secret: "ChangeMe"}, 1)
+	d.Dispatch(newTestChange(), logrus.WithFields(logrus.Fields{}))
+
+	delivery := waitForDelivery(t, d)
+	if delivery.Status != models.DeliveryDelivered {
+		t.Fatalf("expected delivery to succeed, got %s: %s", delivery.Status, delivery.Error)
+	}
+
+	requests := received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	request := requests[0]
+
+	mac := hmac.New(sha256.New, []byte("ChangeMe"))
+	mac.Write(request.body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if signature := request.header.Get(HeaderSignature); signature != expected {
+		t.Errorf("expected signature %s, got %s", expected, signature)
+	}
+	if event := request.header.Get(HeaderEvent); event != models.WebhookEventConfigChanged {
+		t.Errorf("expected event %s, got %s", models.WebhookEventConfigChanged, event)
+	}
+
+	payload := &models.WebhookPayload{}
+	if err := json.Unmarshal(request.body, payload); err != nil {
+		t.Fatalf("body not JSON: %v", err)
+	}
+	if payload.Id != delivery.Id || request.header.Get(HeaderDelivery) != delivery.Id {
+		t.Errorf("expected delivery ID %s in the body and header, got %s and %s", delivery.Id, payload.Id, request.header.Get(HeaderDelivery))
+	}
+	if payload.Domain != "*.t2bot.io" || len(payload.AffectedDomains) != 2 {
+		t.Errorf("unexpected payload: %s", request.body)
+	}
+}
+
+func TestDispatchWithoutSecret(t *testing.T) {
+	server, received := newReceiver(t)
+	defer server.Close()
+
+	d := newTestDispatcher(&config.WebhookConfig{Url: server.URL}, 1)
+	d.Dispatch(newTestChange(), logrus.WithFields(logrus.Fields{}))
+	waitForDelivery(t, d)
+
+	requests := received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	if signature := requests[0].header.Get(HeaderSignature); signature != "" {
+		t.Errorf("expected no signature, got %s", signature)
+	}
+}
+
+func TestDispatchRetries(t *testing.T) {
+	tests := []struct {
+		name               string
+		statusCodes        []int
+		maxAttempts        int
+		expectedStatus     string
+		expectedAttempts   int
+		expectedStatusCode int
+	}{
+		{"delivered after server errors", []int{500, 503}, 5, models.DeliveryDelivered, 3, 200},
+		{"gives up after max attempts", []int{500, 502, 503}, 3, models.DeliveryFailed, 3, 503},
+		{"not found is retried", []int{404}, 2, models.DeliveryDelivered, 2, 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, received := newReceiver(t, test.statusCodes...)
+			defer server.Close()
+
+			d := newTestDispatcher(&config.WebhookConfig{Url: server.URL, Secret: "ChangeMe"}, test.maxAttempts)
+			d.Dispatch(newTestChange(), logrus.WithFields(logrus.Fields{}))
+
+			delivery := waitForDelivery(t, d)
+			if delivery.Status != test.expectedStatus {
+				t.Errorf("expected status %s, got %s", test.expectedStatus, delivery.Status)
+			}
+			if delivery.Attempts != test.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d", test.expectedAttempts, delivery.Attempts)
+			}
+			if delivery.StatusCode != test.expectedStatusCode {
+				t.Errorf("expected status code %d, got %d", test.expectedStatusCode, delivery.StatusCode)
+			}
+			if (delivery.Error == "") != (test.expectedStatus == models.DeliveryDelivered) {
+				t.Errorf("unexpected error %q", delivery.Error)
+			}
+			if delivery.Url != server.URL || delivery.Domain != "*.t2bot.io" || delivery.Action != models.AuditActionPatch {
+				t.Errorf("unexpected delivery: %+v", delivery)
+			}
+
+			requests := received()
+			if len(requests) != test.expectedAttempts {
+				t.Fatalf("expected %d requests, got %d", test.expectedAttempts, len(requests))
+			}
+			for _, request := range requests {
+				if request.header.Get(HeaderDelivery) != delivery.Id {
+					t.Errorf("expected every attempt to have delivery ID %s, got %s", delivery.Id, request.header.Get(HeaderDelivery))
+				}
+				if request.header.Get(HeaderSignature) != Sign("ChangeMe", request.body) {
+					t.Errorf("attempt has the wrong signature")
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchFiltersDomains(t *testing.T) {
+	server, received := newReceiver(t)
+	defer server.Close()
+
+	d := newTestDispatcher(&config.WebhookConfig{Url: server.URL, Domains: []string{"*.t2bot.io"}}, 1)
+	d.Dispatch(&models.ConfigChange{Domain: "example.org", Action: models.AuditActionSet}, logrus.WithFields(logrus.Fields{}))
+	d.Dispatch(newTestChange(), logrus.WithFields(logrus.Fields{}))
+	waitForDelivery(t, d)
+
+	if deliveries := d.Deliveries(); len(deliveries) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(deliveries))
+	}
+	requests := received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	payload := &models.WebhookPayload{}
+	if err := json.Unmarshal(requests[0].body, payload); err != nil {
+		t.Fatalf("body not JSON: %v", err)
+	}
+	if len(payload.AffectedDomains) != 1 || payload.AffectedDomains[0] != "chat.t2bot.io" {
+		t.Errorf("expected only chat.t2bot.io to be affected, got %v", payload.AffectedDomains)
+	}
+}
+
+func TestDispatchDropsWhenQueueFull(t *testing.T) {
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// One delivery is being sent, one is queued, and the rest are dropped
+	d := NewDispatcher([]*config.WebhookConfig{{Url: server.URL}}, &http.Client{Timeout: 5 * time.Second}, 1, time.Millisecond, 10, 1, 1)
+	d.Dispatch(newTestChange(), logrus.WithFields(logrus.Fields{}))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(d.queue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		d.Dispatch(newTestChange(), logrus.WithFields(logrus.Fields{}))
+	}
+
+	failed := 0
+	for _, delivery := range d.Deliveries() {
+		if delivery.Status == models.DeliveryFailed {
+			failed++
+		}
+	}
+	if failed != 2 {
+		t.Errorf("expected 2 dropped deliveries, got %d", failed)
+	}
+}
+
+func TestDeliveryLogLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		added    int
+		expected int
+	}{
+		{"keeps everything under the limit", 3, 2, 2},
+		{"keeps the most recent", 3, 5, 3},
+		{"zero keeps nothing", 0, 2, 0},
+		{"negative keeps nothing", -1, 2, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newDeliveryLog(test.max)
+			for i := 0; i < test.added; i++ {
+				l.add(&models.WebhookDelivery{Id: string(rune('a' + i))})
+			}
+
+			deliveries := l.list()
+			if len(deliveries) != test.expected {
+				t.Fatalf("expected %d deliveries, got %d", test.expected, len(deliveries))
+			}
+			if test.expected > 0 && deliveries[0].Id != string(rune('a'+test.added-1)) {
+				t.Errorf("expected the newest delivery first, got %s", deliveries[0].Id)
+			}
+		})
+	}
+}
+
+func TestCloseWaitsForDeliveries(t *testing.T) {
+	server, received := newReceiver(t, 500)
+	defer server.Close()
+
+	d := newTestDispatcher(&config.WebhookConfig{Url: server.URL}, 2)
+	d.Dispatch(newTestChange(), logrus.WithFields(logrus.Fields{}))
+	d.Close()
+
+	if requests := received(); len(requests) != 2 {
+		t.Errorf("expected the delivery to be retried before closing, got %d requests", len(requests))
+	}
+	if deliveries := d.Deliveries(); deliveries[0].Status != models.DeliveryDelivered {
+		t.Errorf("expected delivery to succeed, got %s", deliveries[0].Status)
+	}
+
+	d.Dispatch(newTestChange(), logrus.WithFields(logrus.Fields{}))
+	if deliveries := d.Deliveries(); len(deliveries) != 2 || deliveries[0].Status != models.DeliveryFailed {
+		t.Errorf("expected deliveries after closing to fail, got %+v", deliveries[0])
+	}
+	d.Close()
+}